@@ -0,0 +1,112 @@
+// Package clock provides an abstraction of the time, so time dependent code can be tested with a fake clock.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time, and waits for durations to elapse.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// Since returns the time elapsed since t.
+	Since(t time.Time) time.Duration
+	// After waits for the duration to elapse, and then sends the current time on the returned channel.
+	After(d time.Duration) <-chan time.Time
+	// Sleep pauses the current goroutine for at least the duration.
+	Sleep(d time.Duration)
+}
+
+// New returns a clock using the wall-clock time.
+func New() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Since(t time.Time) time.Duration {
+	return time.Since(t)
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// FakeClock is a clock whose time changes only when it is advanced. Safe for concurrent use.
+type FakeClock struct {
+	now time.Time
+	// The channels waiting for the time to reach their deadline.
+	waiters []fakeWaiter
+	// A lock on the time and the waiters.
+	lock sync.Mutex
+}
+
+type fakeWaiter struct {
+	deadline time.Time
+	channel  chan time.Time
+}
+
+// NewFakeClock returns a fake clock, set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	channel := make(chan time.Time, 1)
+	if d <= 0 {
+		channel <- c.now
+		return channel
+	}
+	c.waiters = append(c.waiters, fakeWaiter{deadline: c.now.Add(d), channel: channel})
+	return channel
+}
+
+// Sleep blocks until the clock is advanced by at least the duration.
+func (c *FakeClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+// Advance moves the time forward, and wakes up the waiters whose deadline was reached.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+	waiters := c.waiters[:0]
+	for _, waiter := range c.waiters {
+		if waiter.deadline.After(c.now) {
+			waiters = append(waiters, waiter)
+			continue
+		}
+		waiter.channel <- c.now
+	}
+	c.waiters = waiters
+}
+
+// Waiters returns the number of waiters that didn't reach their deadline, so tests can advance the clock once a
+// goroutine started waiting.
+func (c *FakeClock) Waiters() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.waiters)
+}
@@ -0,0 +1,54 @@
+package clock
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRealClock(t *testing.T) {
+	c := New()
+	start := c.Now()
+	c.Sleep(time.Millisecond)
+	assert.GreaterOrEqual(t, c.Since(start), time.Millisecond)
+	assert.GreaterOrEqual(t, (<-c.After(time.Millisecond)).Sub(start), time.Millisecond)
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(start)
+	assert.Equal(t, start, c.Now())
+
+	// Waiters are woken up when their deadline is reached
+	first := c.After(time.Second)
+	second := c.After(time.Minute)
+	assert.Equal(t, 2, c.Waiters())
+	c.Advance(500 * time.Millisecond)
+	assert.Empty(t, first)
+	c.Advance(500 * time.Millisecond)
+	assert.Equal(t, start.Add(time.Second), <-first)
+	assert.Equal(t, 1, c.Waiters())
+	assert.Empty(t, second)
+	c.Advance(time.Hour)
+	assert.Equal(t, start.Add(time.Hour+time.Second), <-second)
+	assert.Zero(t, c.Waiters())
+	assert.Equal(t, time.Hour+time.Second, c.Since(start))
+
+	// A non-positive duration elapses immediately
+	assert.Equal(t, c.Now(), <-c.After(0))
+}
+
+func TestFakeClockSleep(t *testing.T) {
+	c := NewFakeClock(time.Now())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.Sleep(time.Hour)
+	}()
+	assert.Eventually(t, func() bool { return c.Waiters() == 1 }, time.Second, time.Millisecond)
+	c.Advance(time.Hour)
+	wg.Wait()
+}
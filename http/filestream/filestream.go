@@ -7,9 +7,14 @@ import (
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net/textproto"
 	"os"
+	"strconv"
+	"strings"
 
+	"github.com/jfrog/gofrog/crypto"
 	ioutils "github.com/jfrog/gofrog/io"
+	"github.com/minio/sha256-simd"
 )
 
 const (
@@ -17,15 +22,49 @@ const (
 	ErrorType = "error"
 )
 
+// The headers of each file part, used by the receiver to verify the file.
+const (
+	SizeHeader   = "X-File-Size"
+	Sha256Header = "X-Checksum-Sha256"
+)
+
+// Error of this type is sent in an ErrorType part by WriteFilesToStream if a file fails to be sent, and returned by
+// ReadFilesFromStream.
 type MultipartError struct {
 	FileName   string `json:"file_name"`
 	ErrMessage string `json:"error_message"`
 }
 
+func (multipartErr MultipartError) Error() string {
+	return fmt.Sprintf("failed to send '%s' file: %s", multipartErr.FileName, multipartErr.ErrMessage)
+}
+
+// Error of this type will be returned by ReadFilesFromStream if a received file doesn't match the size or the SHA-256
+// checksum sent in its part headers. The file was already written, so the caller should discard it.
+type ChecksumMismatchError struct {
+	FileName       string
+	ExpectedSize   int64
+	ActualSize     int64
+	ExpectedSha256 string
+	ActualSha256   string
+}
+
+func (mismatchErr ChecksumMismatchError) Error() string {
+	if mismatchErr.ExpectedSize != mismatchErr.ActualSize {
+		return fmt.Sprintf("size mismatch for file '%s': expected %d bytes, received %d bytes", mismatchErr.FileName, mismatchErr.ExpectedSize, mismatchErr.ActualSize)
+	}
+	return fmt.Sprintf("SHA-256 mismatch for file '%s': expected %s, received %s", mismatchErr.FileName, mismatchErr.ExpectedSha256, mismatchErr.ActualSha256)
+}
+
 // The expected type of function that should be provided to the ReadFilesFromStream func, that returns the writer that should handle each file
 type FileWriterFunc func(fileName string) (writers []io.WriteCloser, err error)
 
+// ReadFilesFromStream reads the files streamed by WriteFilesToStream and writes each of them to the writers returned by fileWritersFunc.
+// Files sent with size and SHA-256 part headers are verified. A ChecksumMismatchError is reported for each file that
+// fails the verification, and a MultipartError for each file the sender failed to send. The rest of the files are
+// still read, and the per-file errors are returned joined.
 func ReadFilesFromStream(multipartReader *multipart.Reader, fileWritersFunc FileWriterFunc) error {
+	var fileErrs []error
 	for {
 		// Read the next file streamed from client
 		fileReader, err := multipartReader.NextPart()
@@ -35,16 +74,41 @@ func ReadFilesFromStream(multipartReader *multipart.Reader, fileWritersFunc File
 			}
 			return fmt.Errorf("failed to read file: %w", err)
 		}
+		if fileReader.FormName() == ErrorType {
+			multipartErr, err := readErr(fileReader)
+			if err != nil {
+				return errors.Join(append(fileErrs, err)...)
+			}
+			fileErrs = append(fileErrs, multipartErr)
+			continue
+		}
 		if err = readFile(fileReader, fileWritersFunc); err != nil {
-			return err
+			var mismatchErr ChecksumMismatchError
+			if !errors.As(err, &mismatchErr) {
+				return errors.Join(append(fileErrs, err)...)
+			}
+			fileErrs = append(fileErrs, err)
 		}
 
 	}
-	return nil
+	return errors.Join(fileErrs...)
+}
+
+// Decode the MultipartError sent in an ErrorType part.
+func readErr(errReader *multipart.Part) (MultipartError, error) {
+	var multipartErr MultipartError
+	if err := json.NewDecoder(errReader).Decode(&multipartErr); err != nil {
+		return MultipartError{}, fmt.Errorf("failed to decode multipart error: %w", err)
+	}
+	return multipartErr, nil
 }
 
 func readFile(fileReader *multipart.Part, fileWriterFunc FileWriterFunc) (err error) {
 	fileName := fileReader.FileName()
+	expectedSize, expectedSha256, err := getExpectedDetails(fileReader)
+	if err != nil {
+		return err
+	}
 	fileWriter, err := fileWriterFunc(fileName)
 	if err != nil {
 		return err
@@ -56,12 +120,40 @@ func readFile(fileReader *multipart.Part, fileWriterFunc FileWriterFunc) (err er
 		// We read multipart once and write to multiple writers, so we can't use the same multipart writer multiple times
 		writers = append(writers, writer)
 	}
-	if _, err = io.Copy(ioutils.AsyncMultiWriter(10, writers...), fileReader); err != nil {
+	// Sent files without the part headers are not verified
+	verify := expectedSha256 != ""
+	hasher := sha256.New()
+	if verify {
+		writers = append(writers, hasher)
+	}
+	size, err := io.Copy(ioutils.AsyncMultiWriter(10, writers...), fileReader)
+	if err != nil {
 		return fmt.Errorf("failed writing '%s' file: %w", fileName, err)
 	}
+	if !verify {
+		return nil
+	}
+	actualSha256 := fmt.Sprintf("%x", hasher.Sum(nil))
+	if size != expectedSize || !strings.EqualFold(actualSha256, expectedSha256) {
+		return ChecksumMismatchError{FileName: fileName, ExpectedSize: expectedSize, ActualSize: size, ExpectedSha256: expectedSha256, ActualSha256: actualSha256}
+	}
 	return nil
 }
 
+// Returns the size and the SHA-256 checksum sent in the part headers, or an empty checksum if they weren't sent.
+func getExpectedDetails(fileReader *multipart.Part) (size int64, checksum string, err error) {
+	checksum = fileReader.Header.Get(Sha256Header)
+	sizeHeader := fileReader.Header.Get(SizeHeader)
+	if checksum == "" || sizeHeader == "" {
+		return 0, "", nil
+	}
+	size, err = strconv.ParseInt(sizeHeader, 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid %s header of '%s' file: %w", SizeHeader, fileReader.FileName(), err)
+	}
+	return size, checksum, nil
+}
+
 type FileInfo struct {
 	Name string
 	Path string
@@ -87,7 +179,11 @@ func writeFile(multipartWriter *multipart.Writer, file *FileInfo) (err error) {
 		return fmt.Errorf("failed opening file %q: %w", file.Name, err)
 	}
 	defer ioutils.Close(fileReader, &err)
-	fileWriter, err := multipartWriter.CreateFormFile(FileType, file.Name)
+	partHeader, err := createFilePartHeader(fileReader, file)
+	if err != nil {
+		return err
+	}
+	fileWriter, err := multipartWriter.CreatePart(partHeader)
 	if err != nil {
 		return fmt.Errorf("failed to create form file for %q: %w", file.Name, err)
 	}
@@ -95,6 +191,31 @@ func writeFile(multipartWriter *multipart.Writer, file *FileInfo) (err error) {
 	return err
 }
 
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// Create the headers of a form file part, as created by multipart.Writer.CreateFormFile, with the size and the SHA-256
+// checksum of the file. The file is read to calculate its checksum, and then rewound.
+func createFilePartHeader(fileReader *os.File, file *FileInfo) (textproto.MIMEHeader, error) {
+	checksums, err := crypto.CalcChecksums(fileReader, crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed calculating the checksum of %q: %w", file.Name, err)
+	}
+	// The size of the file is the number of bytes read to calculate its checksum
+	size, err := fileReader.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading file %q: %w", file.Name, err)
+	}
+	if _, err = fileReader.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed rewinding file %q: %w", file.Name, err)
+	}
+	partHeader := make(textproto.MIMEHeader)
+	partHeader.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, quoteEscaper.Replace(FileType), quoteEscaper.Replace(file.Name)))
+	partHeader.Set("Content-Type", "application/octet-stream")
+	partHeader.Set(SizeHeader, strconv.FormatInt(size, 10))
+	partHeader.Set(Sha256Header, checksums[crypto.SHA256])
+	return partHeader, nil
+}
+
 func writeErr(multipartWriter *multipart.Writer, file *FileInfo, writeFileErr error) error {
 	fileWriter, err := multipartWriter.CreateFormField(ErrorType)
 	if err != nil {
@@ -3,12 +3,15 @@ package filestream
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
 	"mime/multipart"
+	"net/textproto"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/jfrog/gofrog/crypto"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -73,6 +76,94 @@ func TestWriteFilesToStreamWithError(t *testing.T) {
 	assert.NotEmpty(t, multipartErr.ErrMessage)
 }
 
+func TestWriteFilesToStreamChecksumHeaders(t *testing.T) {
+	sourceDir := t.TempDir()
+	file := &FileInfo{Name: "test.txt", Path: filepath.Join(sourceDir, "test.txt")}
+	assert.NoError(t, os.WriteFile(file.Path, []byte("test content"), 0600))
+	checksums, err := crypto.GetFileChecksums(file.Path, crypto.SHA256)
+	require.NoError(t, err)
+
+	body := &bytes.Buffer{}
+	multipartWriter := multipart.NewWriter(body)
+	assert.NoError(t, WriteFilesToStream(multipartWriter, []*FileInfo{file}))
+
+	// Expect the part headers to carry the size and the checksum of the file
+	multipartReader := multipart.NewReader(body, multipartWriter.Boundary())
+	part, err := multipartReader.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, FileType, part.FormName())
+	assert.Equal(t, file.Name, part.FileName())
+	assert.Equal(t, "12", part.Header.Get(SizeHeader))
+	assert.Equal(t, checksums[crypto.SHA256], part.Header.Get(Sha256Header))
+}
+
+func TestReadFilesFromStreamChecksumMismatch(t *testing.T) {
+	content := []byte("test content")
+	checksums, err := crypto.CalcChecksums(bytes.NewReader(content), crypto.SHA256)
+	require.NoError(t, err)
+	body := &bytes.Buffer{}
+	multipartWriter := multipart.NewWriter(body)
+	writeFilePart(t, multipartWriter, "valid.txt", content, "12", checksums[crypto.SHA256])
+	writeFilePart(t, multipartWriter, "wrong-checksum.txt", content, "12", "0123")
+	writeFilePart(t, multipartWriter, "wrong-size.txt", content, "10", checksums[crypto.SHA256])
+	writeFilePart(t, multipartWriter, "no-headers.txt", content, "", "")
+	assert.NoError(t, multipartWriter.Close())
+
+	// Expect a mismatch error for each corrupted file, while all the files are read
+	targetDir = t.TempDir()
+	err = ReadFilesFromStream(multipart.NewReader(body, multipartWriter.Boundary()), simpleFileWriter)
+	var mismatchErr ChecksumMismatchError
+	require.ErrorAs(t, err, &mismatchErr)
+	assert.Equal(t, ChecksumMismatchError{FileName: "wrong-checksum.txt", ExpectedSize: 12, ActualSize: 12, ExpectedSha256: "0123", ActualSha256: checksums[crypto.SHA256]}, mismatchErr)
+	assert.ErrorContains(t, err, "SHA-256 mismatch for file 'wrong-checksum.txt'")
+	assert.ErrorContains(t, err, "size mismatch for file 'wrong-size.txt': expected 10 bytes, received 12 bytes")
+	assert.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), 2)
+	for _, fileName := range []string{"valid.txt", "wrong-checksum.txt", "wrong-size.txt", "no-headers.txt"} {
+		received, err := os.ReadFile(filepath.Join(targetDir, fileName))
+		assert.NoError(t, err)
+		assert.Equal(t, content, received)
+	}
+}
+
+func TestReadFilesFromStreamWithError(t *testing.T) {
+	content := []byte("test content")
+	body := &bytes.Buffer{}
+	multipartWriter := multipart.NewWriter(body)
+	writeFilePart(t, multipartWriter, "test1.txt", content, "", "")
+	assert.NoError(t, writeErr(multipartWriter, &FileInfo{Name: "failed.txt"}, errors.New("permission denied")))
+	writeFilePart(t, multipartWriter, "test2.txt", content, "", "")
+	assert.NoError(t, multipartWriter.Close())
+
+	// Expect the error sent for the failed file, while the rest of the files are read
+	targetDir = t.TempDir()
+	err := ReadFilesFromStream(multipart.NewReader(body, multipartWriter.Boundary()), simpleFileWriter)
+	var multipartErr MultipartError
+	require.ErrorAs(t, err, &multipartErr)
+	assert.Equal(t, MultipartError{FileName: "failed.txt", ErrMessage: "permission denied"}, multipartErr)
+	assert.EqualError(t, err, "failed to send 'failed.txt' file: permission denied")
+	for _, fileName := range []string{"test1.txt", "test2.txt"} {
+		received, err := os.ReadFile(filepath.Join(targetDir, fileName))
+		assert.NoError(t, err)
+		assert.Equal(t, content, received)
+	}
+	entries, err := os.ReadDir(targetDir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func writeFilePart(t *testing.T, multipartWriter *multipart.Writer, fileName string, content []byte, size, sha256 string) {
+	partHeader := make(textproto.MIMEHeader)
+	partHeader.Set("Content-Disposition", `form-data; name="`+FileType+`"; filename="`+fileName+`"`)
+	if size != "" {
+		partHeader.Set(SizeHeader, size)
+		partHeader.Set(Sha256Header, sha256)
+	}
+	part, err := multipartWriter.CreatePart(partHeader)
+	require.NoError(t, err)
+	_, err = part.Write(content)
+	require.NoError(t, err)
+}
+
 func simpleFileWriter(fileName string) (fileWriter []io.WriteCloser, err error) {
 	writer, err := os.Create(filepath.Join(targetDir, fileName))
 	if err != nil {
@@ -0,0 +1,103 @@
+package retryexecutor
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// BackoffStrategy returns the interval to wait before retrying.
+// attempt - the number of the failed attempt, starting from 0.
+// previous - the interval returned for the previous attempt, or zero after the first attempt.
+type BackoffStrategy func(attempt int, previous time.Duration) time.Duration
+
+// Jitter randomizes the intervals of the exponential backoff, so clients failing together don't retry together.
+type Jitter int
+
+const (
+	// The intervals are not randomized.
+	NoJitter Jitter = iota
+	// A random interval between zero and the exponential interval.
+	FullJitter
+	// Half of the exponential interval, plus a random interval between zero and the other half.
+	EqualJitter
+	// A random interval between the initial interval and three times the previous interval.
+	DecorrelatedJitter
+)
+
+// ConstantBackoff waits the same interval before each retry.
+func ConstantBackoff(interval time.Duration) BackoffStrategy {
+	return func(int, time.Duration) time.Duration {
+		return interval
+	}
+}
+
+// LinearBackoff waits the initial interval before the first retry, and increases the interval by increment before
+// each of the next retries.
+func LinearBackoff(initial, increment time.Duration) BackoffStrategy {
+	return func(attempt int, _ time.Duration) time.Duration {
+		return initial + time.Duration(attempt)*increment
+	}
+}
+
+// ExponentialBackoff waits the initial interval before the first retry, and doubles the interval before each of the
+// next retries, up to the max interval. The intervals are randomized according to the jitter.
+// initial - the interval before the first retry.
+// maxInterval - if positive, the maximum interval.
+func ExponentialBackoff(initial, maxInterval time.Duration, jitter Jitter) BackoffStrategy {
+	return func(attempt int, previous time.Duration) time.Duration {
+		var interval time.Duration
+		switch jitter {
+		case DecorrelatedJitter:
+			interval = randomInterval(initial, 3*max(previous, initial))
+		default:
+			interval = exponentialInterval(initial, maxInterval, attempt)
+			switch jitter {
+			case FullJitter:
+				interval = randomInterval(0, interval)
+			case EqualJitter:
+				interval = interval/2 + randomInterval(0, interval-interval/2)
+			}
+		}
+		if maxInterval > 0 {
+			interval = min(interval, maxInterval)
+		}
+		return interval
+	}
+}
+
+// Returns initial*2^attempt, up to the max interval.
+func exponentialInterval(initial, maxInterval time.Duration, attempt int) time.Duration {
+	interval := initial
+	for i := 0; i < attempt && interval > 0; i++ {
+		if maxInterval > 0 && interval >= maxInterval || interval > math.MaxInt64/2 {
+			break
+		}
+		interval *= 2
+	}
+	return interval
+}
+
+// Returns a random interval in [from, to].
+func randomInterval(from, to time.Duration) time.Duration {
+	if to <= from {
+		return from
+	}
+	return from + rand.N(to-from+1)
+}
+
+// RetryAfterError is returned from the execution handler to suggest the delay before the next attempt, such as the
+// delay from a Retry-After header. The delay overrides the backoff strategy for that attempt.
+type RetryAfterError struct {
+	Delay time.Duration
+	Err   error
+}
+
+func (e RetryAfterError) Error() string {
+	return fmt.Sprintf("%v (retry after %s)", e.Err, e.Delay)
+}
+
+func (e RetryAfterError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,60 @@
+package retryexecutor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConstantBackoff(t *testing.T) {
+	backoff := ConstantBackoff(time.Second)
+	for attempt := 0; attempt < 3; attempt++ {
+		assert.Equal(t, time.Second, backoff(attempt, time.Second))
+	}
+}
+
+func TestLinearBackoff(t *testing.T) {
+	backoff := LinearBackoff(time.Second, 500*time.Millisecond)
+	assert.Equal(t, time.Second, backoff(0, 0))
+	assert.Equal(t, 1500*time.Millisecond, backoff(1, time.Second))
+	assert.Equal(t, 2*time.Second, backoff(2, 1500*time.Millisecond))
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Second, 10*time.Second, NoJitter)
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for attempt, interval := range expected {
+		assert.Equal(t, interval, backoff(attempt, 0))
+	}
+
+	// No overflow without a max interval
+	assert.Positive(t, ExponentialBackoff(time.Second, 0, NoJitter)(100, 0))
+}
+
+func TestExponentialBackoffJitter(t *testing.T) {
+	testCases := []struct {
+		jitter Jitter
+		from   time.Duration
+		to     time.Duration
+	}{
+		{FullJitter, 0, 4 * time.Second},
+		{EqualJitter, 2 * time.Second, 4 * time.Second},
+		{DecorrelatedJitter, time.Second, 5 * time.Second},
+	}
+	for _, testCase := range testCases {
+		backoff := ExponentialBackoff(time.Second, 5*time.Second, testCase.jitter)
+		for i := 0; i < 100; i++ {
+			interval := backoff(2, 3*time.Second)
+			assert.GreaterOrEqual(t, interval, testCase.from)
+			assert.LessOrEqual(t, interval, testCase.to)
+		}
+	}
+}
+
+func TestRetryAfterError(t *testing.T) {
+	err := RetryAfterError{Delay: time.Second, Err: errors.New("too many requests")}
+	assert.EqualError(t, err, "too many requests (retry after 1s)")
+	assert.ErrorIs(t, err, err.Err)
+}
@@ -0,0 +1,50 @@
+package retryexecutor
+
+import (
+	"math"
+	"sync"
+)
+
+// The number of units in a token. The tokens are counted in units, so ratios such as 0.1 add up to whole tokens
+// without floating point errors.
+const tokenUnits = 1_000_000
+
+// RetryBudget limits the retries of the callers sharing it to a ratio of their successful calls, so a failing backend
+// isn't flooded with retries. Each successful call deposits ratio tokens, and each retry withdraws a token.
+// Safe for concurrent use.
+type RetryBudget struct {
+	// The token units deposited by each successful call.
+	ratio int64
+	// The maximum number of token units.
+	maxTokens int64
+	// The available token units.
+	tokens int64
+	// A lock on the tokens.
+	lock sync.Mutex
+}
+
+// Create a new retry budget.
+// ratio - the number of retries allowed per successful call, such as 0.1 to allow a retry per 10 successful calls.
+// maxRetries - the maximum number of retries the budget accumulates, which are also allowed before any call succeeds.
+func NewRetryBudget(ratio float64, maxRetries int) *RetryBudget {
+	maxTokens := int64(maxRetries) * tokenUnits
+	return &RetryBudget{ratio: int64(math.Round(ratio * tokenUnits)), maxTokens: maxTokens, tokens: maxTokens}
+}
+
+// RecordSuccess deposits the tokens of a successful call.
+func (budget *RetryBudget) RecordSuccess() {
+	budget.lock.Lock()
+	defer budget.lock.Unlock()
+	budget.tokens = min(budget.tokens+budget.ratio, budget.maxTokens)
+}
+
+// TryRetry withdraws a token, and returns false if there are no tokens left.
+func (budget *RetryBudget) TryRetry() bool {
+	budget.lock.Lock()
+	defer budget.lock.Unlock()
+	if budget.tokens < tokenUnits {
+		return false
+	}
+	budget.tokens -= tokenUnits
+	return true
+}
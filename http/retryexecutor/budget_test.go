@@ -0,0 +1,109 @@
+package retryexecutor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBudget(t *testing.T) {
+	budget := NewRetryBudget(0.5, 2)
+
+	// The budget starts with the max retries
+	assert.True(t, budget.TryRetry())
+	assert.True(t, budget.TryRetry())
+	assert.False(t, budget.TryRetry())
+
+	// Two successful calls deposit a retry
+	budget.RecordSuccess()
+	assert.False(t, budget.TryRetry())
+	budget.RecordSuccess()
+	assert.True(t, budget.TryRetry())
+
+	// The budget doesn't accumulate more than the max retries
+	for i := 0; i < 10; i++ {
+		budget.RecordSuccess()
+	}
+	assert.True(t, budget.TryRetry())
+	assert.True(t, budget.TryRetry())
+	assert.False(t, budget.TryRetry())
+}
+
+func TestRetryBudgetRatio(t *testing.T) {
+	budget := NewRetryBudget(0.1, 1)
+	assert.True(t, budget.TryRetry())
+
+	// Ten successful calls deposit a retry
+	for i := 0; i < 9; i++ {
+		budget.RecordSuccess()
+		assert.False(t, budget.TryRetry())
+	}
+	budget.RecordSuccess()
+	assert.True(t, budget.TryRetry())
+	assert.False(t, budget.TryRetry())
+}
+
+func TestRetryExecutorRetryBudget(t *testing.T) {
+	budget := NewRetryBudget(1, 3)
+	executionErr := errors.New("service unavailable")
+	runCount := 0
+	executor := RetryExecutor{
+		MaxRetries:  5,
+		RetryBudget: budget,
+		ExecutionHandler: func() (bool, error) {
+			runCount++
+			return true, executionErr
+		},
+	}
+
+	// The executor stops retrying when the budget is exhausted
+	assert.Equal(t, executionErr, executor.Execute())
+	assert.Equal(t, 4, runCount)
+
+	// Executors sharing the budget don't retry until calls succeed
+	runCount = 0
+	assert.Equal(t, executionErr, executor.Execute())
+	assert.Equal(t, 1, runCount)
+	executor.ExecutionHandler = func() (bool, error) {
+		return false, nil
+	}
+	assert.NoError(t, executor.Execute())
+	assert.True(t, budget.TryRetry())
+}
+
+func TestAttemptObserver(t *testing.T) {
+	type observedAttempt struct {
+		attempt int
+		err     error
+		sleep   time.Duration
+	}
+	var observed []observedAttempt
+	var lastElapsed time.Duration
+	executionErr := errors.New("service unavailable")
+	runCount := 0
+	executor := RetryExecutor{
+		MaxRetries: 5,
+		Backoff:    LinearBackoff(time.Millisecond, time.Millisecond),
+		AttemptObserver: func(attempt int, err error, sleep, elapsed time.Duration) {
+			observed = append(observed, observedAttempt{attempt, err, sleep})
+			assert.GreaterOrEqual(t, elapsed, lastElapsed)
+			lastElapsed = elapsed
+		},
+		ExecutionHandler: func() (bool, error) {
+			runCount++
+			if runCount == 3 {
+				return false, nil
+			}
+			return true, executionErr
+		},
+	}
+	assert.NoError(t, executor.Execute())
+	assert.Equal(t, []observedAttempt{
+		{0, executionErr, time.Millisecond},
+		{1, executionErr, 2 * time.Millisecond},
+		{2, nil, 0},
+	}, observed)
+	assert.GreaterOrEqual(t, lastElapsed, 3*time.Millisecond)
+}
@@ -0,0 +1,169 @@
+package retryexecutor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// Calls are allowed. The circuit opens after consecutive failures reach the failure threshold.
+	CircuitClosed CircuitState = iota
+	// Calls fail fast with a CircuitOpenError, until the cool-down passes.
+	CircuitOpen
+	// A single probe call is allowed. The circuit closes if it succeeds, and opens again if it fails.
+	CircuitHalfOpen
+)
+
+func (state CircuitState) String() string {
+	switch state {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(state))
+}
+
+// Error of this type will be returned if a call is not allowed, since its circuit breaker is open.
+type CircuitOpenError struct {
+	// The name of the circuit breaker.
+	Name string
+	// The time a probe call will be allowed.
+	RetryAt time.Time
+}
+
+func (openErr CircuitOpenError) Error() string {
+	name := "circuit breaker"
+	if openErr.Name != "" {
+		name = fmt.Sprintf("circuit breaker '%s'", openErr.Name)
+	}
+	return fmt.Sprintf("%s is open until %s", name, openErr.RetryAt.Format(time.RFC3339))
+}
+
+// CircuitBreaker tracks the health of a backend shared by many callers, such as the retry executors and the round
+// trippers accessing the same server. Once the backend is deemed unhealthy, the calls fail fast with a
+// CircuitOpenError, until a probe call succeeds.
+// Safe for concurrent use.
+type CircuitBreaker struct {
+	// The name of the circuit breaker, used in the errors.
+	Name string
+	// The number of consecutive failures that opens the circuit.
+	failureThreshold int
+	// The duration the circuit stays open before a probe call is allowed.
+	coolDown time.Duration
+	state    CircuitState
+	// The number of consecutive failures while the circuit is closed.
+	failures int
+	// The time the circuit was opened.
+	openedAt time.Time
+	// True while the probe call of the half-open circuit is running.
+	probing bool
+	// A lock on the state.
+	lock sync.Mutex
+}
+
+// Create a new closed circuit breaker.
+// failureThreshold - the number of consecutive failures that opens the circuit, at least 1.
+// coolDown - the duration the circuit stays open before a probe call is allowed.
+func NewCircuitBreaker(failureThreshold int, coolDown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{failureThreshold: max(failureThreshold, 1), coolDown: coolDown}
+}
+
+// Allow returns a CircuitOpenError if a call is not allowed. Otherwise, the caller must report the result of the call
+// with RecordSuccess or RecordFailure.
+func (cb *CircuitBreaker) Allow() error {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	if cb.state == CircuitOpen && !time.Now().Before(cb.openedAt.Add(cb.coolDown)) {
+		cb.state = CircuitHalfOpen
+	}
+	switch cb.state {
+	case CircuitOpen:
+		return CircuitOpenError{Name: cb.Name, RetryAt: cb.openedAt.Add(cb.coolDown)}
+	case CircuitHalfOpen:
+		if cb.probing {
+			return CircuitOpenError{Name: cb.Name, RetryAt: time.Now().Add(cb.coolDown)}
+		}
+		cb.probing = true
+	}
+	return nil
+}
+
+// RecordSuccess reports a successful call. Closes a half-open circuit.
+// Calls that were allowed before the circuit was opened don't close it.
+func (cb *CircuitBreaker) RecordSuccess() {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	switch cb.state {
+	case CircuitClosed:
+		cb.failures = 0
+	case CircuitHalfOpen:
+		cb.state = CircuitClosed
+		cb.failures = 0
+		cb.probing = false
+	}
+}
+
+// RecordFailure reports a failed call. Opens the circuit if the failure threshold is reached, or if the failed call
+// was the probe of a half-open circuit.
+func (cb *CircuitBreaker) RecordFailure() {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	switch cb.state {
+	case CircuitClosed:
+		cb.failures++
+		if cb.failures >= cb.failureThreshold {
+			cb.open()
+		}
+	case CircuitHalfOpen:
+		cb.open()
+	}
+}
+
+// Report the result of a call allowed by the circuit breaker.
+// failed - true if the call failed in a way that may be resolved by retrying it.
+// Calls that exceeded their deadline are failures as well, since the backend didn't respond in time. Other errors, such
+// as a cancelled call or an error that isn't retryable, neither open nor close the circuit.
+func (cb *CircuitBreaker) record(failed bool, err error) {
+	switch {
+	case failed || errors.Is(err, context.DeadlineExceeded):
+		cb.RecordFailure()
+	case err == nil:
+		cb.RecordSuccess()
+	default:
+		cb.releaseProbe()
+	}
+}
+
+// Allow the next call to probe a half-open circuit, without changing its state.
+func (cb *CircuitBreaker) releaseProbe() {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	cb.probing = false
+}
+
+// State returns the current state of the circuit.
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	if cb.state == CircuitOpen && !time.Now().Before(cb.openedAt.Add(cb.coolDown)) {
+		return CircuitHalfOpen
+	}
+	return cb.state
+}
+
+// Open the circuit. Must be called while holding the lock.
+func (cb *CircuitBreaker) open() {
+	cb.state = CircuitOpen
+	cb.openedAt = time.Now()
+	cb.failures = 0
+	cb.probing = false
+}
@@ -0,0 +1,184 @@
+package retryexecutor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	cb := NewCircuitBreaker(3, 50*time.Millisecond)
+	cb.Name = "artifactory"
+	assert.Equal(t, CircuitClosed, cb.State())
+
+	// A success resets the consecutive failures
+	for i := 0; i < 2; i++ {
+		require.NoError(t, cb.Allow())
+		cb.RecordFailure()
+	}
+	require.NoError(t, cb.Allow())
+	cb.RecordSuccess()
+	for i := 0; i < 2; i++ {
+		require.NoError(t, cb.Allow())
+		cb.RecordFailure()
+	}
+	assert.Equal(t, CircuitClosed, cb.State())
+
+	// The circuit opens when the failure threshold is reached
+	require.NoError(t, cb.Allow())
+	cb.RecordFailure()
+	assert.Equal(t, CircuitOpen, cb.State())
+	var openErr CircuitOpenError
+	require.ErrorAs(t, cb.Allow(), &openErr)
+	assert.Equal(t, "artifactory", openErr.Name)
+	assert.Contains(t, openErr.Error(), "circuit breaker 'artifactory' is open until")
+
+	// After the cool-down, a single probe is allowed. A failed probe opens the circuit again
+	assert.Eventually(t, func() bool { return cb.State() == CircuitHalfOpen }, time.Second, time.Millisecond)
+	require.NoError(t, cb.Allow())
+	assert.ErrorAs(t, cb.Allow(), &openErr)
+	cb.RecordFailure()
+	assert.Equal(t, CircuitOpen, cb.State())
+
+	// A successful probe closes the circuit
+	assert.Eventually(t, func() bool { return cb.State() == CircuitHalfOpen }, time.Second, time.Millisecond)
+	require.NoError(t, cb.Allow())
+	cb.RecordSuccess()
+	assert.Equal(t, CircuitClosed, cb.State())
+	assert.NoError(t, cb.Allow())
+}
+
+func TestCircuitBreakerRecord(t *testing.T) {
+	cb := NewCircuitBreaker(2, 50*time.Millisecond)
+
+	// Calls that exceeded their deadline open the circuit
+	for i := 0; i < 2; i++ {
+		require.NoError(t, cb.Allow())
+		cb.record(false, context.DeadlineExceeded)
+	}
+	assert.Equal(t, CircuitOpen, cb.State())
+
+	// A cancelled probe, or a probe that failed with an error that isn't retryable, doesn't close the circuit
+	assert.Eventually(t, func() bool { return cb.State() == CircuitHalfOpen }, time.Second, time.Millisecond)
+	for _, err := range []error{context.Canceled, errors.New("not found")} {
+		require.NoError(t, cb.Allow())
+		cb.record(false, err)
+		assert.Equal(t, CircuitHalfOpen, cb.State())
+	}
+
+	// Only a successful probe closes the circuit
+	require.NoError(t, cb.Allow())
+	cb.record(false, nil)
+	assert.Equal(t, CircuitClosed, cb.State())
+}
+
+func TestCircuitStateString(t *testing.T) {
+	assert.Equal(t, "closed", CircuitClosed.String())
+	assert.Equal(t, "open", CircuitOpen.String())
+	assert.Equal(t, "half-open", CircuitHalfOpen.String())
+}
+
+func TestRetryExecutorCircuitBreaker(t *testing.T) {
+	cb := NewCircuitBreaker(2, time.Hour)
+	runCount := 0
+	executionErr := errors.New("service unavailable")
+	executor := RetryExecutor{
+		MaxRetries:     5,
+		CircuitBreaker: cb,
+		ExecutionHandler: func() (bool, error) {
+			runCount++
+			return true, executionErr
+		},
+	}
+
+	// The executor stops once the circuit opens
+	assert.ErrorAs(t, executor.Execute(), &CircuitOpenError{})
+	assert.Equal(t, 2, runCount)
+
+	// Other executors sharing the circuit breaker fail fast
+	assert.ErrorAs(t, executor.Execute(), &CircuitOpenError{})
+	assert.Equal(t, 2, runCount)
+}
+
+func TestRetryRoundTripperCircuitBreaker(t *testing.T) {
+	server, requests, _ := newStatusCodesServer(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	cb := NewCircuitBreaker(2, time.Hour)
+	client := newRetryClient(RetryPolicy{MaxRetries: 5, CircuitBreaker: cb})
+	_, err := client.Get(server.URL)
+	assert.ErrorAs(t, err, &CircuitOpenError{})
+	assert.Equal(t, int32(2), requests.Load())
+
+	// Requests that are not retried consult the circuit breaker as well
+	_, err = client.Post(server.URL, "text/plain", nil)
+	assert.ErrorAs(t, err, &CircuitOpenError{})
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// A request body recording whether it was closed.
+type closeRecordingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *closeRecordingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestRetryRoundTripperCircuitBreakerClosesBody(t *testing.T) {
+	cb := NewCircuitBreaker(1, time.Hour)
+	cb.RecordFailure()
+	rt := &RetryRoundTripper{
+		Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			t.Fatal("the request was sent while the circuit is open")
+			return nil, nil
+		}),
+		Policy: RetryPolicy{MaxRetries: 3, CircuitBreaker: cb},
+	}
+	body := &closeRecordingBody{Reader: strings.NewReader("content")}
+	req, err := http.NewRequest(http.MethodPut, "http://localhost", body)
+	require.NoError(t, err)
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("content")), nil
+	}
+
+	// Expect the body of the rejected request to be closed
+	_, err = rt.RoundTrip(req)
+	assert.ErrorAs(t, err, &CircuitOpenError{})
+	assert.True(t, body.closed)
+}
+
+func TestRetryRoundTripperCircuitBreakerTimeout(t *testing.T) {
+	// A server that doesn't respond until the request is cancelled
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	cb := NewCircuitBreaker(2, time.Hour)
+	client := newRetryClient(RetryPolicy{MaxRetries: 5, CircuitBreaker: cb})
+
+	// Requests timing out open the circuit
+	for i := 0; i < 2; i++ {
+		ctx, cancelFunc := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		_, err = client.Do(req)
+		cancelFunc()
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+	assert.Equal(t, CircuitOpen, cb.State())
+}
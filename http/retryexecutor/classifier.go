@@ -0,0 +1,92 @@
+package retryexecutor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// Classifier decides whether an error is retryable.
+type Classifier func(err error) bool
+
+// StatusCodeError is the error of an HTTP response with an unexpected status code.
+type StatusCodeError struct {
+	StatusCode int
+	// The underlying error, if any.
+	Err error
+}
+
+func (e StatusCodeError) Error() string {
+	message := fmt.Sprintf("server response: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Err != nil {
+		message = fmt.Sprintf("%s: %s", message, e.Err.Error())
+	}
+	return message
+}
+
+func (e StatusCodeError) Unwrap() error {
+	return e.Err
+}
+
+// IsNetworkTimeout is true if the error is a net.Error that timed out.
+func IsNetworkTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// IsConnectionError is true if the error is a transient connection failure: a network timeout, a failed network
+// operation such as a refused or reset connection, or a connection closed before the response was complete.
+// Permanent errors, such as an unsupported protocol scheme or an invalid certificate, are not connection errors.
+func IsConnectionError(err error) bool {
+	if IsNetworkTimeout(err) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// RetryableStatusCodes returns a classifier of StatusCodeError errors, retrying the given status codes.
+func RetryableStatusCodes(statusCodes ...int) Classifier {
+	return func(err error) bool {
+		var statusCodeErr StatusCodeError
+		if !errors.As(err, &statusCodeErr) {
+			return false
+		}
+		for _, statusCode := range statusCodes {
+			if statusCodeErr.StatusCode == statusCode {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RetryableErrors returns a classifier retrying the errors that wrap one of the given sentinel errors.
+func RetryableErrors(sentinels ...error) Classifier {
+	return func(err error) bool {
+		for _, sentinel := range sentinels {
+			if errors.Is(err, sentinel) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// AnyOf returns a classifier retrying the errors that one of the given classifiers retries.
+func AnyOf(classifiers ...Classifier) Classifier {
+	return func(err error) bool {
+		for _, classifier := range classifiers {
+			if classifier(err) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,60 @@
+package retryexecutor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsNetworkTimeout(t *testing.T) {
+	timeoutErr := &net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}
+	assert.True(t, IsNetworkTimeout(fmt.Errorf("upload failed: %w", timeoutErr)))
+	assert.False(t, IsNetworkTimeout(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+	assert.False(t, IsNetworkTimeout(errors.New("some error")))
+}
+
+func TestIsConnectionError(t *testing.T) {
+	assert.True(t, IsConnectionError(&net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}))
+	assert.True(t, IsConnectionError(fmt.Errorf("upload failed: %w", &net.OpError{Op: "read", Err: errors.New("broken pipe")})))
+	assert.True(t, IsConnectionError(fmt.Errorf("upload failed: %w", syscall.ECONNRESET)))
+	assert.True(t, IsConnectionError(syscall.ECONNREFUSED))
+	assert.True(t, IsConnectionError(io.ErrUnexpectedEOF))
+	assert.False(t, IsConnectionError(errors.New(`unsupported protocol scheme "ftp"`)))
+	assert.False(t, IsConnectionError(errors.New("some error")))
+}
+
+func TestRetryableStatusCodes(t *testing.T) {
+	classifier := RetryableStatusCodes(http.StatusTooManyRequests, http.StatusServiceUnavailable)
+	assert.True(t, classifier(StatusCodeError{StatusCode: http.StatusTooManyRequests}))
+	assert.True(t, classifier(fmt.Errorf("download failed: %w", StatusCodeError{StatusCode: http.StatusServiceUnavailable})))
+	assert.False(t, classifier(StatusCodeError{StatusCode: http.StatusNotFound}))
+	assert.False(t, classifier(errors.New("some error")))
+}
+
+func TestRetryableErrors(t *testing.T) {
+	classifier := RetryableErrors(errRetryable, os.ErrDeadlineExceeded)
+	assert.True(t, classifier(fmt.Errorf("upload failed: %w", errRetryable)))
+	assert.True(t, classifier(os.ErrDeadlineExceeded))
+	assert.False(t, classifier(errors.New("some error")))
+}
+
+func TestAnyOf(t *testing.T) {
+	classifier := AnyOf(IsNetworkTimeout, RetryableStatusCodes(http.StatusBadGateway))
+	assert.True(t, classifier(&net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}))
+	assert.True(t, classifier(StatusCodeError{StatusCode: http.StatusBadGateway}))
+	assert.False(t, classifier(StatusCodeError{StatusCode: http.StatusNotFound}))
+}
+
+func TestStatusCodeError(t *testing.T) {
+	assert.EqualError(t, StatusCodeError{StatusCode: http.StatusNotFound}, "server response: 404 Not Found")
+	err := StatusCodeError{StatusCode: http.StatusBadGateway, Err: errRetryable}
+	assert.EqualError(t, err, "server response: 502 Bad Gateway: retryable error")
+	assert.ErrorIs(t, err, errRetryable)
+}
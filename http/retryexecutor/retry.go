@@ -0,0 +1,260 @@
+package retryexecutor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jfrog/gofrog/clock"
+	"github.com/jfrog/gofrog/log"
+)
+
+// OperationFunc is an operation that returns a value, run with retries by Do.
+// attempt - the number of the attempt, starting from 0.
+type OperationFunc[T any] func(ctx context.Context, attempt int) (T, error)
+
+// AttemptObserver is invoked after each attempt, for example to export metrics.
+// attempt - the number of the attempt, starting from 0.
+// err - the error returned in the attempt.
+// sleep - the interval before the next attempt, or zero if the attempt will not be retried.
+// elapsed - the time passed since the first attempt started.
+type AttemptObserver func(attempt int, err error, sleep, elapsed time.Duration)
+
+// RetryPolicy configures the retries of Do.
+type RetryPolicy struct {
+	// The amount of retries to perform.
+	MaxRetries int
+
+	// The strategy of the intervals between retries. If nil, the retries are performed without waiting.
+	Backoff BackoffStrategy
+
+	// If positive, the maximum interval between retries. Doesn't limit the delay suggested by a RetryAfterError.
+	MaxInterval time.Duration
+
+	// If positive, the maximum delay suggested by a RetryAfterError. If the suggested delay is longer, the retries stop.
+	MaxRetryAfter time.Duration
+
+	// If positive, stop retrying when the next retry would start after this duration passes since the first attempt.
+	MaxElapsedTime time.Duration
+
+	// Decides whether a failed attempt should be retried. If nil, all the errors are retried.
+	IsRetryable Classifier
+
+	// If not nil, each attempt is allowed only if the circuit breaker is not open. Otherwise, the retries stop with a
+	// CircuitOpenError. Attempts that fail with a retryable error are reported to the circuit breaker as failures.
+	CircuitBreaker *CircuitBreaker
+
+	// If not nil, the retries stop when the retry budget is exhausted. Successful attempts are reported to the budget.
+	RetryBudget *RetryBudget
+
+	// If not nil, invoked after each attempt.
+	AttemptObserver AttemptObserver
+
+	// The clock used to wait between the attempts and to measure the elapsed time. If nil, the wall-clock time is used.
+	Clock clock.Clock
+
+	// Message to display when retrying.
+	ErrorMessage string
+
+	// Prefix to print at the beginning of each log.
+	LogMsgPrefix string
+}
+
+// Do runs the operation until it succeeds, its error isn't retryable, or the retries are exhausted.
+// Returns the value and the error of the last attempt. The waits between the attempts stop when ctx is done, and the
+// retries stop when ctx is cancelled (returning context.Canceled) or its deadline is exceeded (returning a DeadlineError).
+// The operation may return a RetryAfterError to override the interval before the next attempt.
+func Do[T any](ctx context.Context, policy RetryPolicy, operation OperationFunc[T]) (T, error) {
+	var value T
+	exhausted, err := policy.execute(ctx, func(ctx context.Context, attempt int) (bool, error) {
+		var err error
+		value, err = operation(ctx, attempt)
+		return err != nil && policy.isRetryable(err), err
+	})
+	if exhausted {
+		log.Info(policy.getTimeoutErrorMsg())
+	}
+	return value, err
+}
+
+// Run the attempts until one of them shouldn't be retried, or the retries are exhausted.
+// Returns true if the retries were exhausted, and the error of the last attempt.
+func (policy *RetryPolicy) execute(ctx context.Context, attemptFunc func(ctx context.Context, attempt int) (shouldRetry bool, err error)) (exhausted bool, err error) {
+	attemptCtx := ctx
+	if attemptCtx == nil {
+		attemptCtx = context.Background()
+	}
+	var shouldRetry, retry bool
+	var interval, attemptDuration time.Duration
+	start := policy.clock().Now()
+	for i := 0; ; i++ {
+		if policy.CircuitBreaker != nil {
+			if openErr := policy.CircuitBreaker.Allow(); openErr != nil {
+				return false, openErr
+			}
+		}
+		// Run the attempt
+		attemptStart := policy.clock().Now()
+		shouldRetry, err = attemptFunc(attemptCtx, i)
+		attemptDuration = policy.clock().Since(attemptStart)
+		policy.recordAttempt(shouldRetry, err)
+
+		// If we should not retry, return.
+		if !shouldRetry {
+			policy.observe(i, err, 0, start)
+			return false, err
+		}
+		if cancelledErr := checkCancelled(ctx, err); cancelledErr != nil {
+			policy.observe(i, err, 0, start)
+			return false, cancelledErr
+		}
+
+		// Print retry log message
+		policy.logRetry(i, err)
+
+		interval, retry = policy.nextRetry(i, interval, err, start)
+		if !retry {
+			policy.observe(i, err, 0, start)
+			return true, err
+		}
+		policy.observe(i, err, interval, start)
+		// Going to sleep for the interval of the backoff strategy, or the delay suggested by the attempt.
+		if waitErr := wait(ctx, policy.clock(), interval, attemptDuration, err); waitErr != nil {
+			return false, waitErr
+		}
+	}
+}
+
+// Returns the interval to wait before retrying the failed attempt, and false if the retries are exhausted.
+func (policy *RetryPolicy) nextRetry(attempt int, previous time.Duration, err error, start time.Time) (time.Duration, bool) {
+	if attempt >= policy.MaxRetries {
+		return 0, false
+	}
+	var retryAfterErr RetryAfterError
+	if policy.MaxRetryAfter > 0 && errors.As(err, &retryAfterErr) && retryAfterErr.Delay > policy.MaxRetryAfter {
+		log.Info(fmt.Sprintf("The suggested retry delay of %s exceeds the maximum of %s", retryAfterErr.Delay, policy.MaxRetryAfter))
+		return 0, false
+	}
+	interval := policy.nextInterval(attempt, previous, err)
+	if policy.MaxElapsedTime > 0 && policy.clock().Since(start)+interval > policy.MaxElapsedTime {
+		return 0, false
+	}
+	if policy.RetryBudget != nil && !policy.RetryBudget.TryRetry() {
+		log.Info("Retry budget exhausted")
+		return 0, false
+	}
+	return interval, true
+}
+
+func (policy *RetryPolicy) observe(attempt int, err error, sleep time.Duration, start time.Time) {
+	if policy.AttemptObserver != nil {
+		policy.AttemptObserver(attempt, err, sleep, policy.clock().Since(start))
+	}
+}
+
+func (policy *RetryPolicy) clock() clock.Clock {
+	if policy.Clock == nil {
+		return clock.New()
+	}
+	return policy.Clock
+}
+
+// Report the result of the attempt to the circuit breaker and to the retry budget.
+// Only retryable errors are failures, since other errors, such as validation errors, don't indicate an unhealthy backend.
+func (policy *RetryPolicy) recordAttempt(shouldRetry bool, err error) {
+	if policy.CircuitBreaker != nil {
+		policy.CircuitBreaker.record(shouldRetry && err != nil, err)
+	}
+	if policy.RetryBudget != nil && !shouldRetry && err == nil {
+		policy.RetryBudget.RecordSuccess()
+	}
+}
+
+func (policy *RetryPolicy) isRetryable(err error) bool {
+	return policy.IsRetryable == nil || policy.IsRetryable(err)
+}
+
+func (policy *RetryPolicy) getTimeoutErrorMsg() string {
+	prefix := ""
+	if policy.LogMsgPrefix != "" {
+		prefix = policy.LogMsgPrefix + " "
+	}
+	return fmt.Sprintf("%sexecutor timeout after %v attempts", prefix, policy.MaxRetries)
+}
+
+// Returns the interval to wait after the failed attempt.
+func (policy *RetryPolicy) nextInterval(attempt int, previous time.Duration, err error) time.Duration {
+	var retryAfterErr RetryAfterError
+	if errors.As(err, &retryAfterErr) && retryAfterErr.Delay > 0 {
+		return retryAfterErr.Delay
+	}
+	if policy.Backoff == nil {
+		return 0
+	}
+	interval := policy.Backoff(attempt, previous)
+	if policy.MaxInterval > 0 {
+		interval = min(interval, policy.MaxInterval)
+	}
+	return interval
+}
+
+func (policy *RetryPolicy) logRetry(attemptNumber int, err error) {
+	message := fmt.Sprintf("%s(Attempt %v)", policy.LogMsgPrefix, attemptNumber+1)
+	if policy.ErrorMessage != "" {
+		message = fmt.Sprintf("%s - %s", message, policy.ErrorMessage)
+	}
+	if err != nil {
+		message = fmt.Sprintf("%s: %s", message, err.Error())
+	}
+
+	if err != nil || policy.ErrorMessage != "" {
+		log.Warn(message)
+	} else {
+		log.Debug(message)
+	}
+}
+
+// Returns an error if the context was cancelled or its deadline was exceeded.
+// lastErr - the error returned in the last attempt.
+func checkCancelled(ctx context.Context, lastErr error) error {
+	if ctx == nil {
+		return nil
+	}
+	contextErr := ctx.Err()
+	if errors.Is(contextErr, context.Canceled) {
+		log.Info("Retry executor was cancelled")
+		return contextErr
+	}
+	if errors.Is(contextErr, context.DeadlineExceeded) {
+		log.Info("Retry executor deadline exceeded")
+		return DeadlineError{Err: lastErr}
+	}
+	return nil
+}
+
+// Wait for the interval before the next attempt. Returns an error if the context is cancelled meanwhile, or if the
+// next attempt can't complete before the context's deadline, assuming it takes as long as the last attempt.
+// lastAttemptDuration - the duration of the last attempt, used as the estimated duration of the next attempt.
+// lastErr - the error returned in the last attempt.
+func wait(ctx context.Context, clock clock.Clock, interval, lastAttemptDuration time.Duration, lastErr error) error {
+	if ctx == nil {
+		if interval > 0 {
+			clock.Sleep(interval)
+		}
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && !clock.Now().Add(interval+lastAttemptDuration).Before(deadline) {
+		log.Info("Retry executor deadline would be exceeded before the next attempt completes")
+		return DeadlineError{Err: lastErr}
+	}
+	if interval <= 0 {
+		return nil
+	}
+	select {
+	case <-clock.After(interval):
+		return nil
+	case <-ctx.Done():
+		return checkCancelled(ctx, lastErr)
+	}
+}
@@ -0,0 +1,75 @@
+package retryexecutor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errRetryable = errors.New("retryable error")
+
+func TestDo(t *testing.T) {
+	var attempts []int
+	value, err := Do(context.Background(), RetryPolicy{MaxRetries: 5}, func(_ context.Context, attempt int) (string, error) {
+		attempts = append(attempts, attempt)
+		if attempt < 2 {
+			return "", errRetryable
+		}
+		return "value", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+	assert.Equal(t, []int{0, 1, 2}, attempts)
+}
+
+func TestDoExhausted(t *testing.T) {
+	runCount := 0
+	policy := RetryPolicy{MaxRetries: 3, Backoff: ConstantBackoff(time.Millisecond)}
+	_, err := Do(context.Background(), policy, func(context.Context, int) (int, error) {
+		runCount++
+		return 0, errRetryable
+	})
+	assert.Equal(t, errRetryable, err)
+	assert.Equal(t, policy.MaxRetries+1, runCount)
+}
+
+func TestDoNotRetryable(t *testing.T) {
+	runCount := 0
+	notRetryableErr := errors.New("not retryable error")
+	policy := RetryPolicy{MaxRetries: 3, IsRetryable: RetryableErrors(errRetryable)}
+	_, err := Do(context.Background(), policy, func(context.Context, int) (int, error) {
+		runCount++
+		return 0, notRetryableErr
+	})
+	assert.Equal(t, notRetryableErr, err)
+	assert.Equal(t, 1, runCount)
+}
+
+func TestDoStatusCodes(t *testing.T) {
+	statusCodes := []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusNotFound, http.StatusOK}
+	policy := RetryPolicy{MaxRetries: 5, IsRetryable: RetryableStatusCodes(http.StatusBadGateway, http.StatusServiceUnavailable)}
+	runCount := 0
+	_, err := Do(context.Background(), policy, func(context.Context, int) (bool, error) {
+		statusCode := statusCodes[runCount]
+		runCount++
+		return false, StatusCodeError{StatusCode: statusCode}
+	})
+	assert.EqualError(t, err, "server response: 404 Not Found")
+	assert.Equal(t, 3, runCount)
+}
+
+func TestDoCancel(t *testing.T) {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	runCount := 0
+	_, err := Do(ctx, RetryPolicy{MaxRetries: 5}, func(attemptCtx context.Context, _ int) (int, error) {
+		runCount++
+		cancelFunc()
+		return 0, attemptCtx.Err()
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, runCount)
+}
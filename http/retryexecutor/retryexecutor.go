@@ -2,24 +2,50 @@ package retryexecutor
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"github.com/jfrog/gofrog/clock"
 	"github.com/jfrog/gofrog/log"
 	"time"
 )
 
+// The handler may return a RetryAfterError to override the interval before the next attempt.
 type ExecutionHandlerFunc func() (shouldRetry bool, err error)
 
 type RetryExecutor struct {
-	// The context
+	// The context. If it is cancelled or its deadline is exceeded, the executor stops retrying.
 	Context context.Context
 
 	// The amount of retries to perform.
 	MaxRetries int
 
-	// Number of milliseconds to sleep between retries.
+	// Number of milliseconds to sleep between retries. Ignored if Backoff is set.
 	RetriesIntervalMilliSecs int
 
+	// The strategy of the intervals between retries. If nil, RetriesIntervalMilliSecs is used.
+	Backoff BackoffStrategy
+
+	// If positive, the maximum interval between retries. Doesn't limit the delay suggested by a RetryAfterError.
+	MaxInterval time.Duration
+
+	// If positive, the maximum delay suggested by a RetryAfterError. If the suggested delay is longer, the executor stops retrying.
+	MaxRetryAfter time.Duration
+
+	// If positive, the executor stops retrying when the next retry would start after this duration passes since the first attempt.
+	MaxElapsedTime time.Duration
+
+	// If not nil, each attempt is allowed only if the circuit breaker is not open. Otherwise, the executor stops with a
+	// CircuitOpenError. Attempts that should be retried and return an error are reported to the circuit breaker as failures.
+	CircuitBreaker *CircuitBreaker
+
+	// If not nil, the executor stops retrying when the retry budget is exhausted. Successful attempts are reported to the budget.
+	RetryBudget *RetryBudget
+
+	// If not nil, invoked after each attempt.
+	AttemptObserver AttemptObserver
+
+	// The clock used to wait between the attempts and to measure the elapsed time. If nil, the wall-clock time is used.
+	Clock clock.Clock
+
 	// Message to display when retrying.
 	ErrorMessage string
 
@@ -31,27 +57,11 @@ type RetryExecutor struct {
 }
 
 func (runner *RetryExecutor) Execute() error {
-	var err error
-	var shouldRetry bool
-	for i := 0; i <= runner.MaxRetries; i++ {
-		// Run ExecutionHandler
-		shouldRetry, err = runner.ExecutionHandler()
-
-		// If we should not retry, return.
-		if !shouldRetry {
-			return err
-		}
-		if cancelledErr := runner.checkCancelled(); cancelledErr != nil {
-			return cancelledErr
-		}
-
-		// Print retry log message
-		runner.LogRetry(i, err)
-
-		// Going to sleep for RetryInterval milliseconds
-		if runner.RetriesIntervalMilliSecs > 0 && i < runner.MaxRetries {
-			time.Sleep(time.Millisecond * time.Duration(runner.RetriesIntervalMilliSecs))
-		}
+	exhausted, err := runner.policy().execute(runner.Context, func(context.Context, int) (bool, error) {
+		return runner.ExecutionHandler()
+	})
+	if !exhausted {
+		return err
 	}
 	// If the error is not nil, return it and log the timeout message. Otherwise, generate new error.
 	if err != nil {
@@ -61,6 +71,27 @@ func (runner *RetryExecutor) Execute() error {
 	return TimeoutError{runner.getTimeoutErrorMsg()}
 }
 
+// Returns the retry policy of the executor.
+func (runner *RetryExecutor) policy() *RetryPolicy {
+	backoff := runner.Backoff
+	if backoff == nil && runner.RetriesIntervalMilliSecs > 0 {
+		backoff = ConstantBackoff(time.Millisecond * time.Duration(runner.RetriesIntervalMilliSecs))
+	}
+	return &RetryPolicy{
+		MaxRetries:      runner.MaxRetries,
+		Backoff:         backoff,
+		MaxInterval:     runner.MaxInterval,
+		MaxRetryAfter:   runner.MaxRetryAfter,
+		MaxElapsedTime:  runner.MaxElapsedTime,
+		CircuitBreaker:  runner.CircuitBreaker,
+		RetryBudget:     runner.RetryBudget,
+		AttemptObserver: runner.AttemptObserver,
+		Clock:           runner.Clock,
+		ErrorMessage:    runner.ErrorMessage,
+		LogMsgPrefix:    runner.LogMsgPrefix,
+	}
+}
+
 // Error of this type will be returned if the executor reaches timeout and no other error is returned by the execution handler.
 type TimeoutError struct {
 	errMsg string
@@ -79,29 +110,26 @@ func (runner *RetryExecutor) getTimeoutErrorMsg() string {
 }
 
 func (runner *RetryExecutor) LogRetry(attemptNumber int, err error) {
-	message := fmt.Sprintf("%s(Attempt %v)", runner.LogMsgPrefix, attemptNumber+1)
-	if runner.ErrorMessage != "" {
-		message = fmt.Sprintf("%s - %s", message, runner.ErrorMessage)
-	}
-	if err != nil {
-		message = fmt.Sprintf("%s: %s", message, err.Error())
-	}
+	runner.policy().logRetry(attemptNumber, err)
+}
 
-	if err != nil || runner.ErrorMessage != "" {
-		log.Warn(message)
-	} else {
-		log.Debug(message)
-	}
+// Error of this type will be returned if the context's deadline stops the executor before it reaches timeout.
+// Can be checked with errors.Is(err, context.DeadlineExceeded).
+type DeadlineError struct {
+	// The error returned by the execution handler in the last attempt, if any.
+	Err error
 }
 
-func (runner *RetryExecutor) checkCancelled() error {
-	if runner.Context == nil {
-		return nil
+func (deadlineErr DeadlineError) Error() string {
+	if deadlineErr.Err != nil {
+		return fmt.Sprintf("retry executor deadline exceeded: %s", deadlineErr.Err.Error())
 	}
-	contextErr := runner.Context.Err()
-	if errors.Is(contextErr, context.Canceled) {
-		log.Info("Retry executor was cancelled")
-		return contextErr
+	return "retry executor deadline exceeded"
+}
+
+func (deadlineErr DeadlineError) Unwrap() []error {
+	if deadlineErr.Err != nil {
+		return []error{context.DeadlineExceeded, deadlineErr.Err}
 	}
-	return nil
+	return []error{context.DeadlineExceeded}
 }
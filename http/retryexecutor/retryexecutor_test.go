@@ -3,9 +3,11 @@ package retryexecutor
 import (
 	"context"
 	"errors"
+	"github.com/jfrog/gofrog/clock"
 	"github.com/jfrog/gofrog/log"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestRetryExecutorSuccess(t *testing.T) {
@@ -88,3 +90,242 @@ func TestRetryExecutorCancel(t *testing.T) {
 	assert.EqualError(t, executor.Execute(), context.Canceled.Error())
 	assert.Equal(t, 1, runCount)
 }
+
+func TestRetryExecutorBackoff(t *testing.T) {
+	var intervals []time.Duration
+	executor := RetryExecutor{
+		MaxRetries:  4,
+		Backoff:     ExponentialBackoff(time.Millisecond, 0, NoJitter),
+		MaxInterval: 4 * time.Millisecond,
+		ExecutionHandler: func() (bool, error) {
+			return true, nil
+		},
+	}
+	var previous time.Duration
+	for attempt := 0; attempt < executor.MaxRetries; attempt++ {
+		previous = executor.policy().nextInterval(attempt, previous, nil)
+		intervals = append(intervals, previous)
+	}
+	assert.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond, 4 * time.Millisecond}, intervals)
+	assert.IsType(t, TimeoutError{}, executor.Execute())
+}
+
+func TestRetryExecutorRetryAfter(t *testing.T) {
+	runCount := 0
+	retryAfterErr := RetryAfterError{Delay: 50 * time.Millisecond, Err: errors.New("too many requests")}
+	executor := RetryExecutor{
+		MaxRetries: 1,
+		Backoff:    ConstantBackoff(time.Hour),
+		ExecutionHandler: func() (bool, error) {
+			runCount++
+			if runCount == 1 {
+				return true, retryAfterErr
+			}
+			return false, nil
+		},
+	}
+
+	// The suggested delay overrides the backoff strategy
+	start := time.Now()
+	assert.NoError(t, executor.Execute())
+	assert.Equal(t, 2, runCount)
+	assert.GreaterOrEqual(t, time.Since(start), retryAfterErr.Delay)
+	assert.Less(t, time.Since(start), time.Hour)
+}
+
+func TestRetryExecutorMaxRetryAfter(t *testing.T) {
+	runCount := 0
+	retryAfterErr := RetryAfterError{Delay: time.Hour, Err: errors.New("too many requests")}
+	executor := RetryExecutor{
+		MaxRetries:    3,
+		MaxRetryAfter: time.Minute,
+		ExecutionHandler: func() (bool, error) {
+			runCount++
+			return true, retryAfterErr
+		},
+	}
+
+	// A suggested delay longer than the maximum stops the retries
+	start := time.Now()
+	assert.Equal(t, retryAfterErr, executor.Execute())
+	assert.Equal(t, 1, runCount)
+	assert.Less(t, time.Since(start), time.Minute)
+}
+
+func TestRetryExecutorMaxElapsedTime(t *testing.T) {
+	runCount := 0
+	executionErr := errors.New("retry failed due to reason")
+	executor := RetryExecutor{
+		MaxRetries:     100,
+		Backoff:        ConstantBackoff(100 * time.Millisecond),
+		MaxElapsedTime: 250 * time.Millisecond,
+		ExecutionHandler: func() (bool, error) {
+			runCount++
+			return true, executionErr
+		},
+	}
+
+	// Stop retrying when the next retry would start after the max elapsed time
+	assert.Equal(t, executionErr, executor.Execute())
+	assert.Equal(t, 3, runCount)
+}
+
+func TestRetryExecutorCancelWhileWaiting(t *testing.T) {
+	runCount := 0
+	retryContext, cancelFunc := context.WithCancel(context.Background())
+	executor := RetryExecutor{
+		Context:                  retryContext,
+		MaxRetries:               5,
+		RetriesIntervalMilliSecs: int(time.Hour.Milliseconds()),
+		ExecutionHandler: func() (bool, error) {
+			runCount++
+			return true, nil
+		},
+	}
+
+	// Cancelling the context stops the wait between the attempts
+	time.AfterFunc(10*time.Millisecond, cancelFunc)
+	start := time.Now()
+	assert.ErrorIs(t, executor.Execute(), context.Canceled)
+	assert.Less(t, time.Since(start), time.Minute)
+	assert.Equal(t, 1, runCount)
+}
+
+func TestRetryExecutorDeadline(t *testing.T) {
+	runCount := 0
+	executionErr := errors.New("retry failed due to reason")
+	retryContext, cancelFunc := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelFunc()
+	executor := RetryExecutor{
+		Context:                  retryContext,
+		MaxRetries:               100,
+		RetriesIntervalMilliSecs: 20,
+		ExecutionHandler: func() (bool, error) {
+			runCount++
+			if runCount == 1 {
+				return true, executionErr
+			}
+			// Wait for the deadline
+			<-retryContext.Done()
+			return true, executionErr
+		},
+	}
+
+	// Expect the deadline to stop the retries with a distinguishable error
+	err := executor.Execute()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorIs(t, err, executionErr)
+	assert.IsType(t, DeadlineError{}, err)
+	assert.Equal(t, 2, runCount)
+}
+
+func TestRetryExecutorSkipAttemptAfterDeadline(t *testing.T) {
+	runCount := 0
+	retryContext, cancelFunc := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelFunc()
+	executor := RetryExecutor{
+		Context:    retryContext,
+		MaxRetries: 5,
+		Backoff:    ConstantBackoff(time.Hour),
+		ExecutionHandler: func() (bool, error) {
+			runCount++
+			return true, nil
+		},
+	}
+
+	// The next attempt would start after the deadline, so the executor returns without waiting
+	start := time.Now()
+	err := executor.Execute()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.EqualError(t, err, "retry executor deadline exceeded")
+	assert.Less(t, time.Since(start), time.Minute)
+	assert.Equal(t, 1, runCount)
+
+	// The next attempt would start before the deadline, but can't complete before it if it takes as long as the first
+	fakeClock := clock.NewFakeClock(time.Now())
+	retryContext, cancelFunc = context.WithDeadline(context.Background(), fakeClock.Now().Add(10*time.Minute))
+	defer cancelFunc()
+	runCount = 0
+	executor = RetryExecutor{
+		Context:    retryContext,
+		MaxRetries: 5,
+		Backoff:    ConstantBackoff(time.Minute),
+		Clock:      fakeClock,
+		ExecutionHandler: func() (bool, error) {
+			runCount++
+			fakeClock.Advance(6 * time.Minute)
+			return true, nil
+		},
+	}
+	errChan := make(chan error)
+	go func() {
+		errChan <- executor.Execute()
+	}()
+	select {
+	case err = <-errChan:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, 1, runCount)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the executor waited for an attempt that can't complete before the deadline")
+	}
+}
+
+func TestRetryExecutorFakeClock(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	var elapsed []time.Duration
+	executor := RetryExecutor{
+		MaxRetries: 2,
+		Backoff:    ConstantBackoff(time.Hour),
+		Clock:      fakeClock,
+		AttemptObserver: func(_ int, _ error, _, elapsedTime time.Duration) {
+			elapsed = append(elapsed, elapsedTime)
+		},
+		ExecutionHandler: func() (bool, error) {
+			return true, nil
+		},
+	}
+
+	// Expect the executor to wait for the fake clock instead of the wall-clock time
+	errChan := make(chan error)
+	go func() {
+		errChan <- executor.Execute()
+	}()
+	for i := 0; i < 2; i++ {
+		assert.Eventually(t, func() bool { return fakeClock.Waiters() == 1 }, time.Second*5, time.Millisecond)
+		fakeClock.Advance(time.Hour)
+	}
+	assert.IsType(t, TimeoutError{}, <-errChan)
+	assert.Equal(t, []time.Duration{0, time.Hour, 2 * time.Hour}, elapsed)
+}
+
+func TestRetryExecutorFakeClockDeadline(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	retryContext, cancelFunc := context.WithDeadline(context.Background(), fakeClock.Now().Add(90*time.Minute))
+	defer cancelFunc()
+	runCount := 0
+	executor := RetryExecutor{
+		Context:    retryContext,
+		MaxRetries: 5,
+		Backoff:    ConstantBackoff(time.Hour),
+		Clock:      fakeClock,
+		ExecutionHandler: func() (bool, error) {
+			runCount++
+			return true, nil
+		},
+	}
+
+	// Expect the deadline to be checked against the fake clock, so the executor returns before the second wait
+	errChan := make(chan error)
+	go func() {
+		errChan <- executor.Execute()
+	}()
+	assert.Eventually(t, func() bool { return fakeClock.Waiters() == 1 }, time.Second*5, time.Millisecond)
+	fakeClock.Advance(time.Hour)
+	select {
+	case err := <-errChan:
+		assert.IsType(t, DeadlineError{}, err)
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "the executor didn't return before the deadline of the fake clock")
+	}
+	assert.Equal(t, 2, runCount)
+}
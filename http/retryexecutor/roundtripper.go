@@ -0,0 +1,198 @@
+package retryexecutor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The maximum number of bytes read from the body of a retried response, so its connection can be reused.
+const maxDrainedBodySize = 64 * 1024
+
+// DefaultRetryableStatusCodes are the status codes retried by a RetryRoundTripper without RetryableStatusCodes.
+var DefaultRetryableStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// DefaultMaxRetryAfter is the longest Retry-After delay a RetryRoundTripper waits for, if its policy doesn't set
+// MaxRetryAfter. Responses suggesting a longer delay are returned without retrying.
+const DefaultMaxRetryAfter = time.Minute
+
+// RetryRoundTripper is an http.RoundTripper retrying idempotent requests that fail with a connection error (see
+// IsConnectionError), or with a retryable status code. Use it as the Transport of an http.Client.
+// Requests with a body are retried only if their body can be rewound with GetBody, as set by http.NewRequest.
+// The Retry-After header of a retried response overrides the backoff strategy for that attempt, up to the policy's
+// MaxRetryAfter, or DefaultMaxRetryAfter if it isn't set.
+// If the retries are exhausted, the last response is returned.
+type RetryRoundTripper struct {
+	// The round tripper sending the requests. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// The retry policy. The context of each request stops its retries.
+	// If IsRetryable is set, it decides which transport errors are retried instead of IsConnectionError.
+	// If CircuitBreaker is set, it is consulted for all the requests, including requests that are not retried.
+	Policy RetryPolicy
+
+	// The status codes to retry. If nil, DefaultRetryableStatusCodes are retried.
+	RetryableStatusCodes []int
+}
+
+// errRewindBody is returned if the body of a request can't be rewound, so the request isn't retried.
+var errRewindBody = errors.New("failed to rewind the request body")
+
+func (rt *RetryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := rt.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if !isIdempotent(req) || !canRewindBody(req) {
+		return rt.roundTripOnce(transport, req)
+	}
+
+	policy := rt.Policy
+	policy.IsRetryable = rt.isRetryable
+	if policy.MaxRetryAfter <= 0 {
+		policy.MaxRetryAfter = DefaultMaxRetryAfter
+	}
+	// The response of the last attempt that failed with a retryable status code.
+	var lastResp *http.Response
+	// True once the request was passed to the transport, which closes its body.
+	sent := false
+	resp, err := Do(req.Context(), policy, func(ctx context.Context, attempt int) (*http.Response, error) {
+		drainAndClose(lastResp)
+		lastResp = nil
+		attemptReq := req
+		if attempt > 0 && req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", errRewindBody, err)
+			}
+			attemptReq = req.Clone(ctx)
+			attemptReq.Body = body
+		}
+		sent = true
+		resp, err := transport.RoundTrip(attemptReq)
+		if err != nil {
+			return nil, err
+		}
+		if !rt.isRetryableStatusCode(resp.StatusCode) {
+			return resp, nil
+		}
+		lastResp = resp
+		var attemptErr error = StatusCodeError{StatusCode: resp.StatusCode}
+		if delay, ok := ParseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			attemptErr = RetryAfterError{Delay: delay, Err: attemptErr}
+		}
+		return nil, attemptErr
+	})
+	if !sent && req.Body != nil {
+		// The request was rejected before its first attempt, such as by an open circuit breaker.
+		_ = req.Body.Close()
+	}
+	if err != nil && lastResp != nil {
+		var statusCodeErr StatusCodeError
+		if errors.As(err, &statusCodeErr) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+			// The retries were exhausted, so the caller handles the last response.
+			return lastResp, nil
+		}
+		drainAndClose(lastResp)
+	}
+	return resp, err
+}
+
+// Send the request without retries. The circuit breaker of the policy is still consulted.
+func (rt *RetryRoundTripper) roundTripOnce(transport http.RoundTripper, req *http.Request) (*http.Response, error) {
+	circuitBreaker := rt.Policy.CircuitBreaker
+	if circuitBreaker == nil {
+		return transport.RoundTrip(req)
+	}
+	if err := circuitBreaker.Allow(); err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+	resp, err := transport.RoundTrip(req)
+	circuitBreaker.record(err != nil && rt.isRetryable(err) || err == nil && rt.isRetryableStatusCode(resp.StatusCode), err)
+	return resp, err
+}
+
+func (rt *RetryRoundTripper) isRetryable(err error) bool {
+	if errors.Is(err, errRewindBody) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var statusCodeErr StatusCodeError
+	if errors.As(err, &statusCodeErr) {
+		// Only responses with a retryable status code are converted to errors.
+		return true
+	}
+	if rt.Policy.IsRetryable == nil {
+		return IsConnectionError(err)
+	}
+	return rt.Policy.IsRetryable(err)
+}
+
+func (rt *RetryRoundTripper) isRetryableStatusCode(statusCode int) bool {
+	statusCodes := rt.RetryableStatusCodes
+	if statusCodes == nil {
+		statusCodes = DefaultRetryableStatusCodes
+	}
+	for _, retryableStatusCode := range statusCodes {
+		if statusCode == retryableStatusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRetryAfter parses the value of a Retry-After header, which is either a number of seconds or an HTTP date.
+// Returns false if the value is empty or invalid. A date in the past is parsed as a zero delay.
+func ParseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(time.Until(date), 0), true
+}
+
+// Returns true if the request can be sent more than once with the same effect, as defined by net/http.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	_, hasIdempotencyKey := req.Header["Idempotency-Key"]
+	_, hasXIdempotencyKey := req.Header["X-Idempotency-Key"]
+	return hasIdempotencyKey || hasXIdempotencyKey
+}
+
+func canRewindBody(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// Read the rest of the response body, so the connection can be reused, and close it.
+func drainAndClose(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainedBodySize))
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,204 @@
+package retryexecutor
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns a server responding with the status codes in order, and then with 200.
+func newStatusCodesServer(t *testing.T, statusCodes ...int) (*httptest.Server, *atomic.Int32, *[]string) {
+	var requests atomic.Int32
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		bodies = append(bodies, string(body))
+		request := int(requests.Add(1)) - 1
+		if request < len(statusCodes) {
+			w.WriteHeader(statusCodes[request])
+			return
+		}
+		_, err = w.Write([]byte("ok"))
+		assert.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests, &bodies
+}
+
+func newRetryClient(policy RetryPolicy) *http.Client {
+	return &http.Client{Transport: &RetryRoundTripper{Policy: policy}}
+}
+
+func TestRetryRoundTripper(t *testing.T) {
+	server, requests, _ := newStatusCodesServer(t, http.StatusServiceUnavailable, http.StatusBadGateway)
+	resp, err := newRetryClient(RetryPolicy{MaxRetries: 3}).Get(server.URL)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, resp.Body.Close()) }()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", string(body))
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestRetryRoundTripperExhausted(t *testing.T) {
+	// Expect the last response when the retries are exhausted
+	server, requests, _ := newStatusCodesServer(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	resp, err := newRetryClient(RetryPolicy{MaxRetries: 1}).Get(server.URL)
+	require.NoError(t, err)
+	assert.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestRetryRoundTripperStatusCodes(t *testing.T) {
+	// Not retryable status code
+	server, requests, _ := newStatusCodesServer(t, http.StatusNotFound)
+	resp, err := newRetryClient(RetryPolicy{MaxRetries: 3}).Get(server.URL)
+	require.NoError(t, err)
+	assert.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, int32(1), requests.Load())
+
+	// Configured retryable status code
+	server, requests, _ = newStatusCodesServer(t, http.StatusNotFound)
+	client := &http.Client{Transport: &RetryRoundTripper{Policy: RetryPolicy{MaxRetries: 3}, RetryableStatusCodes: []int{http.StatusNotFound}}}
+	resp, err = client.Get(server.URL)
+	require.NoError(t, err)
+	assert.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestRetryRoundTripperRewindBody(t *testing.T) {
+	server, requests, bodies := newStatusCodesServer(t, http.StatusServiceUnavailable)
+	req, err := http.NewRequest(http.MethodPut, server.URL, strings.NewReader("content"))
+	require.NoError(t, err)
+	resp, err := newRetryClient(RetryPolicy{MaxRetries: 3}).Do(req)
+	require.NoError(t, err)
+	assert.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), requests.Load())
+	assert.Equal(t, []string{"content", "content"}, *bodies)
+}
+
+func TestRetryRoundTripperNotIdempotent(t *testing.T) {
+	// POST requests are not retried
+	server, requests, _ := newStatusCodesServer(t, http.StatusServiceUnavailable)
+	resp, err := newRetryClient(RetryPolicy{MaxRetries: 3}).Post(server.URL, "text/plain", strings.NewReader("content"))
+	require.NoError(t, err)
+	assert.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), requests.Load())
+
+	// Requests with a body that can't be rewound are not retried
+	server, requests, _ = newStatusCodesServer(t, http.StatusServiceUnavailable)
+	req, err := http.NewRequest(http.MethodPut, server.URL, io.NopCloser(strings.NewReader("content")))
+	require.NoError(t, err)
+	resp, err = newRetryClient(RetryPolicy{MaxRetries: 3}).Do(req)
+	require.NoError(t, err)
+	assert.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestRetryRoundTripperRetryAfter(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	// The Retry-After header overrides the backoff strategy
+	start := time.Now()
+	resp, err := newRetryClient(RetryPolicy{MaxRetries: 1, Backoff: ConstantBackoff(time.Hour)}).Get(server.URL)
+	require.NoError(t, err)
+	assert.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+	assert.Less(t, time.Since(start), time.Hour)
+}
+
+func TestRetryRoundTripperMaxRetryAfter(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "86400")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	// A Retry-After delay longer than the default maximum returns the response without retrying
+	resp, err := newRetryClient(RetryPolicy{MaxRetries: 3}).Get(server.URL)
+	require.NoError(t, err)
+	assert.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestRetryRoundTripperConnectionError(t *testing.T) {
+	// A closed server refuses the connections
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	server.Close()
+	runCount := 0
+	policy := RetryPolicy{MaxRetries: 2, IsRetryable: func(error) bool {
+		runCount++
+		return true
+	}}
+	_, err := newRetryClient(policy).Get(server.URL)
+	assert.Error(t, err)
+	assert.Equal(t, 3, runCount)
+}
+
+func TestRetryRoundTripperNotConnectionError(t *testing.T) {
+	// Expect an error that isn't a connection error to be attempted once
+	attempts := 0
+	policy := RetryPolicy{MaxRetries: 3, AttemptObserver: func(int, error, time.Duration, time.Duration) {
+		attempts++
+	}}
+	_, err := newRetryClient(policy).Get("ftp://localhost/file")
+	assert.ErrorContains(t, err, "unsupported protocol scheme")
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetryRoundTripperCancel(t *testing.T) {
+	server, requests, _ := newStatusCodesServer(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancelFunc()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	_, err = newRetryClient(RetryPolicy{MaxRetries: 3, Backoff: ConstantBackoff(time.Hour)}).Do(req)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	delay, ok := ParseRetryAfter("120")
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Minute, delay)
+
+	delay, ok = ParseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.True(t, ok)
+	assert.Greater(t, delay, 59*time.Minute)
+
+	delay, ok = ParseRetryAfter("Wed, 21 Oct 2015 07:28:00 GMT")
+	assert.True(t, ok)
+	assert.Zero(t, delay)
+
+	for _, value := range []string{"", "-1", "soon"} {
+		_, ok = ParseRetryAfter(value)
+		assert.False(t, ok, value)
+	}
+}
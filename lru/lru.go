@@ -3,6 +3,8 @@ package lru
 import (
 	"sync"
 	"time"
+
+	"github.com/jfrog/gofrog/clock"
 )
 
 type Cache struct {
@@ -25,6 +27,13 @@ func WithExpiry(expiry time.Duration) func(c *Cache) {
 	}
 }
 
+// WithClock sets the clock used to expire the entries, such as a fake clock in tests.
+func WithClock(clock clock.Clock) func(c *Cache) {
+	return func(c *Cache) {
+		c.cache.clock = clock
+	}
+}
+
 func WithEvictionCallback(onEvicted func(key string, value interface{})) func(c *Cache) {
 	return func(c *Cache) {
 		c.cache.OnEvicted = onEvicted
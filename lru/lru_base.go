@@ -7,6 +7,8 @@ package lru
 import (
 	"container/list"
 	"time"
+
+	"github.com/jfrog/gofrog/clock"
 )
 
 type cacheBase struct {
@@ -19,6 +21,9 @@ type cacheBase struct {
 
 	ll    *list.List
 	cache map[string]*list.Element
+
+	// The clock used to expire the entries.
+	clock clock.Clock
 }
 
 type entry struct {
@@ -28,13 +33,13 @@ type entry struct {
 }
 
 func newCacheBase(size int) *cacheBase {
-	return &cacheBase{Size: size, cache: make(map[string]*list.Element), ll: list.New()}
+	return &cacheBase{Size: size, cache: make(map[string]*list.Element), ll: list.New(), clock: clock.New()}
 }
 
 func (c *cacheBase) Add(key string, value interface{}) {
 	var epochNow int64
 	if c.Expiry != time.Duration(0) {
-		epochNow = time.Now().UnixNano() / int64(time.Millisecond)
+		epochNow = c.clock.Now().UnixNano() / int64(time.Millisecond)
 	}
 	if ee, ok := c.cache[key]; ok {
 		c.ll.MoveToFront(ee)
@@ -54,7 +59,7 @@ func (c *cacheBase) Add(key string, value interface{}) {
 func (c *cacheBase) Get(key string) (value interface{}, ok bool) {
 	if ele, hit := c.cache[key]; hit {
 		if c.Expiry != time.Duration(0) {
-			unixNow := time.Now().UnixNano() / int64(time.Millisecond)
+			unixNow := c.clock.Now().UnixNano() / int64(time.Millisecond)
 			unixExpiry := int64(c.Expiry / time.Millisecond)
 			if ent, ok := ele.Value.(*entry); ok {
 				if (unixNow - ent.timeInsert) > unixExpiry {
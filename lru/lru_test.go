@@ -3,6 +3,8 @@ package lru
 import (
 	"testing"
 	"time"
+
+	"github.com/jfrog/gofrog/clock"
 )
 
 func TestGet(t *testing.T) {
@@ -114,10 +116,11 @@ func TestPurge(t *testing.T) {
 }
 
 func TestExpiry(t *testing.T) {
-	c := New(3, WithExpiry(time.Second))
+	fakeClock := clock.NewFakeClock(time.Now())
+	c := New(3, WithExpiry(time.Second), WithClock(fakeClock))
 	c.Add("e1", 1)
 	c.Add("e2", 2)
-	time.Sleep(500 * time.Millisecond)
+	fakeClock.Advance(500 * time.Millisecond)
 	c.Add("e3", 3)
 	if _, ok := c.Get("e1"); !ok {
 		t.Fatal("Expected to get value for e1 but it was not found")
@@ -132,7 +135,7 @@ func TestExpiry(t *testing.T) {
 	if l != 3 {
 		t.Fatalf("Expected length to be 3 but got %d", l)
 	}
-	time.Sleep(700 * time.Millisecond)
+	fakeClock.Advance(700 * time.Millisecond)
 	if _, ok := c.Get("e1"); ok {
 		t.Fatal("Expected not to get value for e1 but it was found")
 	}
@@ -0,0 +1,123 @@
+package parallel
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDecreaseFactor   = 0.5
+	defaultDecreaseCooldown = time.Second
+)
+
+// OverloadError is returned from a task when the server it accesses is overloaded, for example when the server
+// responds with 429 (Too Many Requests) or 503 (Service Unavailable).
+// An AdaptiveController lowers the parallelism of the runner when its tasks fail with this error.
+type OverloadError struct {
+	// The HTTP status code of the response, or zero if unknown.
+	StatusCode int
+	// The underlying error.
+	Err error
+}
+
+func (e OverloadError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("server is overloaded (status code %d): %v", e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("server is overloaded: %v", e.Err)
+}
+
+func (e OverloadError) Unwrap() error {
+	return e.Err
+}
+
+// AdaptiveRunner is a runner whose parallelism can be adjusted according to the results of its tasks.
+type AdaptiveRunner interface {
+	SetMaxParallel(int)
+	MaxParallel() int
+	OnTaskDone(TaskDoneHook)
+}
+
+// AdaptiveController adjusts the parallelism of a runner according to the results of its tasks, using AIMD
+// (additive increase, multiplicative decrease).
+// When a task fails with an OverloadError, or runs longer than the latency threshold, the parallelism is multiplied
+// by the decrease factor. After each window of successful tasks, as many as the current parallelism, the parallelism
+// is increased by one. Other errors don't change the parallelism.
+type AdaptiveController struct {
+	runner      AdaptiveRunner
+	minParallel int
+	maxParallel int
+	// If positive, tasks running longer than this threshold are handled as a sign of overload.
+	LatencyThreshold time.Duration
+	// The factor the parallelism is multiplied by when the server is overloaded, between 0 and 1.
+	DecreaseFactor float64
+	// The minimum duration between two decreases, so tasks failing together decrease the parallelism only once.
+	DecreaseCooldown time.Duration
+	// The number of successful tasks since the last change of the parallelism.
+	successes int
+	// The time of the last decrease.
+	lastDecrease time.Time
+	// A lock on the controller's state.
+	lock sync.Mutex
+}
+
+// Create a new adaptive controller, and register it to the runner. Must be called before Run.
+// The fields of the controller can be changed before Run.
+// runner - the runner whose parallelism is adjusted.
+// minParallel - the lowest parallelism, at least 1.
+// maxParallel - the highest parallelism, at least minParallel.
+func NewAdaptiveController(runner AdaptiveRunner, minParallel, maxParallel int) *AdaptiveController {
+	minParallel = max(minParallel, 1)
+	c := &AdaptiveController{
+		runner:           runner,
+		minParallel:      minParallel,
+		maxParallel:      max(maxParallel, minParallel),
+		DecreaseFactor:   defaultDecreaseFactor,
+		DecreaseCooldown: defaultDecreaseCooldown,
+	}
+	runner.OnTaskDone(func(_ int, err error, duration time.Duration) {
+		c.observe(err, duration)
+	})
+	return c
+}
+
+// Adjust the parallelism according to the result of a task attempt.
+func (c *AdaptiveController) observe(err error, duration time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	current := c.runner.MaxParallel()
+	if c.isOverload(err, duration) {
+		if !c.lastDecrease.IsZero() && time.Since(c.lastDecrease) < c.DecreaseCooldown {
+			return
+		}
+		c.lastDecrease = time.Now()
+		c.successes = 0
+		c.setParallelism(min(int(float64(current)*c.DecreaseFactor), current-1))
+		return
+	}
+	if err != nil {
+		return
+	}
+	c.successes++
+	if c.successes >= current {
+		c.successes = 0
+		c.setParallelism(current + 1)
+	}
+}
+
+func (c *AdaptiveController) isOverload(err error, duration time.Duration) bool {
+	var overloadError OverloadError
+	if errors.As(err, &overloadError) {
+		return true
+	}
+	return c.LatencyThreshold > 0 && duration > c.LatencyThreshold
+}
+
+func (c *AdaptiveController) setParallelism(parallelism int) {
+	parallelism = min(max(parallelism, c.minParallel), c.maxParallel)
+	if parallelism != c.runner.MaxParallel() {
+		c.runner.SetMaxParallel(parallelism)
+	}
+}
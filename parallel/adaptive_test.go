@@ -0,0 +1,132 @@
+package parallel
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A runner that only records its parallelism and hooks.
+type parallelismRunner struct {
+	maxParallel int
+	hooks       []TaskDoneHook
+}
+
+func (r *parallelismRunner) MaxParallel() int {
+	return r.maxParallel
+}
+
+func (r *parallelismRunner) SetMaxParallel(maxParallel int) {
+	r.maxParallel = maxParallel
+}
+
+func (r *parallelismRunner) OnTaskDone(hook TaskDoneHook) {
+	r.hooks = append(r.hooks, hook)
+}
+
+func (r *parallelismRunner) taskDone(err error, duration time.Duration) {
+	for _, hook := range r.hooks {
+		hook(0, err, duration)
+	}
+}
+
+func TestOverloadError(t *testing.T) {
+	err := OverloadError{StatusCode: http.StatusTooManyRequests, Err: errTest}
+	assert.EqualError(t, err, "server is overloaded (status code 429): some error")
+	assert.ErrorIs(t, err, errTest)
+	assert.EqualError(t, OverloadError{Err: errTest}, "server is overloaded: some error")
+}
+
+func TestAdaptiveControllerIncrease(t *testing.T) {
+	runner := &parallelismRunner{maxParallel: 2}
+	NewAdaptiveController(runner, 1, 4)
+
+	// Increase by one after each window of successful tasks
+	for _, expected := range []int{2, 3, 3, 3, 4} {
+		runner.taskDone(nil, time.Millisecond)
+		assert.Equal(t, expected, runner.maxParallel)
+	}
+
+	// Never increase above the maximum
+	for i := 0; i < 10; i++ {
+		runner.taskDone(nil, time.Millisecond)
+	}
+	assert.Equal(t, 4, runner.maxParallel)
+
+	// Other errors don't change the parallelism
+	runner.taskDone(errTest, time.Millisecond)
+	assert.Equal(t, 4, runner.maxParallel)
+}
+
+func TestAdaptiveControllerDecrease(t *testing.T) {
+	runner := &parallelismRunner{maxParallel: 8}
+	controller := NewAdaptiveController(runner, 3, 8)
+	controller.DecreaseCooldown = 0
+
+	// Halve the parallelism on overload, but not below the minimum
+	overloadError := fmt.Errorf("upload failed: %w", OverloadError{StatusCode: http.StatusServiceUnavailable})
+	for _, expected := range []int{4, 3, 3} {
+		runner.taskDone(overloadError, time.Millisecond)
+		assert.Equal(t, expected, runner.maxParallel)
+	}
+
+	// Slow tasks are a sign of overload
+	runner.maxParallel = 6
+	controller.LatencyThreshold = time.Second
+	runner.taskDone(nil, 2*time.Second)
+	assert.Equal(t, 3, runner.maxParallel)
+}
+
+func TestAdaptiveControllerDecreaseCooldown(t *testing.T) {
+	runner := &parallelismRunner{maxParallel: 8}
+	NewAdaptiveController(runner, 1, 8)
+
+	// Tasks failing together decrease the parallelism once
+	for i := 0; i < 4; i++ {
+		runner.taskDone(OverloadError{StatusCode: http.StatusTooManyRequests}, time.Millisecond)
+	}
+	assert.Equal(t, 4, runner.maxParallel)
+}
+
+func TestAdaptiveControllerWithRunner(t *testing.T) {
+	// Create new runner, with tasks overloading the server when running in parallel
+	const count = 20
+	runner := NewRunner(4, count, false)
+	controller := NewAdaptiveController(runner, 1, 4)
+	controller.DecreaseCooldown = 0
+	var running int
+	var lock sync.Mutex
+	minParallel := runner.MaxParallel()
+	runner.OnTaskDone(func(int, error, time.Duration) {
+		lock.Lock()
+		defer lock.Unlock()
+		minParallel = min(minParallel, runner.MaxParallel())
+	})
+	for i := 0; i < count; i++ {
+		_, err := runner.AddTask(func(int) error {
+			lock.Lock()
+			running++
+			overloaded := running > 1
+			lock.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			lock.Lock()
+			defer lock.Unlock()
+			running--
+			if overloaded {
+				return OverloadError{StatusCode: http.StatusTooManyRequests}
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+	runner.Done()
+	runner.Run()
+
+	// Expect the parallelism to back off to a single thread
+	assert.Equal(t, 1, minParallel)
+	assert.Equal(t, uint32(count), runner.Stats().Succeeded+runner.Stats().Failed)
+}
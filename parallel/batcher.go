@@ -0,0 +1,215 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BatchFunc processes a batch of items, such as setting properties on a batch of artifacts with a single request.
+// Returns the error of each item, in the order of the items, or nil if all the items succeeded.
+// If err isn't nil, all the items of the batch fail with it.
+type BatchFunc[T any] func(threadId int, items []T) (itemErrors []error, err error)
+
+// Batcher is a runner that accumulates the added items, and runs them in batches.
+// A batch is added as a single task when it reaches the batch size, or when the flush interval passes since its first
+// item was added. The result of each item is reported through the channel returned when the item was added.
+type Batcher[T any] struct {
+	*runner
+	// Processes the batches.
+	process BatchFunc[T]
+	// The maximum number of items in a batch.
+	batchSize int
+	// If positive, the maximum duration an item waits for its batch to be added.
+	flushInterval time.Duration
+	// The items of the next batch.
+	items []T
+	// The channels receiving the results of the items of the next batch.
+	results []chan error
+	// Flushes the next batch when the flush interval passes.
+	timer *time.Timer
+	// The number of the next batch, so the timer of a batch that was already flushed doesn't flush the next batch.
+	batchNum int
+	// True when Done was invoked.
+	done bool
+	// The items of the batches removed by Drain.
+	drainedItems []T
+	// Waits for the flushed batches to be added to the runner.
+	flushing sync.WaitGroup
+	// A lock on the next batch.
+	lock sync.Mutex
+}
+
+// Create a new batcher.
+// maxParallel - number of go routines for batch processing, maxParallel always will be a positive number.
+// capacity - number of batches that can be added until a free processing goroutine is needed.
+// failFast - is set to true the will stop on first error.
+// batchSize - the maximum number of items in a batch, at least 1.
+// flushInterval - if positive, the maximum duration an item waits for its batch to be added.
+// process - processes the batches.
+func NewBatcher[T any](maxParallel int, capacity uint, failFast bool, batchSize int, flushInterval time.Duration, process BatchFunc[T]) *Batcher[T] {
+	return &Batcher[T]{
+		runner:        NewRunner(maxParallel, capacity, failFast),
+		process:       process,
+		batchSize:     max(batchSize, 1),
+		flushInterval: flushInterval,
+	}
+}
+
+// AddItem adds an item to the next batch. If the batch is full, it is added to the runner, which may block while the
+// queue is full.
+// Returns a channel receiving the result of the item once its batch is processed: nil if the item succeeded, or its
+// error otherwise. If the runner is cancelled before the batch is processed, the channel receives an error as well.
+func (b *Batcher[T]) AddItem(item T) (<-chan error, error) {
+	result := make(chan error, 1)
+	b.lock.Lock()
+	if b.done || b.cancel.Load() {
+		b.lock.Unlock()
+		return nil, errors.New("runner stopped")
+	}
+	b.items = append(b.items, item)
+	b.results = append(b.results, result)
+	if len(b.items) >= b.batchSize {
+		items, results := b.takeBatch()
+		b.flushing.Add(1)
+		b.lock.Unlock()
+		return result, b.submit(items, results)
+	}
+	if len(b.items) == 1 && b.flushInterval > 0 {
+		batchNum := b.batchNum
+		b.timer = time.AfterFunc(b.flushInterval, func() {
+			b.flushBatch(batchNum)
+		})
+	}
+	b.lock.Unlock()
+	return result, nil
+}
+
+// Flush adds the next batch to the runner, even if it isn't full.
+func (b *Batcher[T]) Flush() error {
+	b.lock.Lock()
+	items, results := b.takeBatch()
+	b.flushing.Add(1)
+	b.lock.Unlock()
+	return b.submit(items, results)
+}
+
+// Done flushes the items that were added and were not processed yet, and notifies that no more items will be added.
+func (b *Batcher[T]) Done() {
+	b.lock.Lock()
+	b.done = true
+	items, results := b.takeBatch()
+	b.flushing.Add(1)
+	b.lock.Unlock()
+	// The runner is stopped on failure, and the error is reported to the items.
+	_ = b.submit(items, results)
+	b.flushing.Wait()
+	b.runner.Done()
+}
+
+// Cancel stops the batcher. Items that were not processed receive an error.
+// force - If true, pending batches in the queue will not be handled.
+func (b *Batcher[T]) Cancel(force bool) {
+	b.runner.Cancel(force)
+	b.lock.Lock()
+	_, results := b.takeBatch()
+	b.lock.Unlock()
+	reportBatchResults(results, nil, errors.New("runner stopped"))
+}
+
+// Drain removes the batches that didn't start from the queue, including the next batch, and returns their items in
+// the order they were added. Typically called after Pause, so the returned items can be persisted and added to a
+// batcher later. The channels of the drained items receive an error.
+func (b *Batcher[T]) Drain() []T {
+	b.runner.Drain()
+	b.lock.Lock()
+	items, results := b.takeBatch()
+	drainedItems := append(b.drainedItems, items...)
+	b.drainedItems = nil
+	b.lock.Unlock()
+	reportBatchResults(results, nil, errors.New("batch drained"))
+	return drainedItems
+}
+
+// Flush the batch if it wasn't flushed yet.
+func (b *Batcher[T]) flushBatch(batchNum int) {
+	b.lock.Lock()
+	if batchNum != b.batchNum {
+		b.lock.Unlock()
+		return
+	}
+	items, results := b.takeBatch()
+	b.flushing.Add(1)
+	b.lock.Unlock()
+	// The error is reported to the items.
+	_ = b.submit(items, results)
+}
+
+// Take the items of the next batch, and start a new batch. Must be called while holding the lock.
+func (b *Batcher[T]) takeBatch() ([]T, []chan error) {
+	items, results := b.items, b.results
+	b.items, b.results = nil, nil
+	b.batchNum++
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return items, results
+}
+
+// Add the batch to the runner. If the batch can't be added, the error is reported to its items.
+// The caller must increment the flushing WaitGroup while holding the lock.
+func (b *Batcher[T]) submit(items []T, results []chan error) error {
+	defer b.flushing.Done()
+	if len(items) == 0 {
+		return nil
+	}
+	var itemErrors []error
+	var batchErr error
+	batchTask := &task{}
+	batchTask.run = func(_ context.Context, threadId int) error {
+		itemErrors, batchErr = b.process(threadId, items)
+		if batchErr == nil && itemErrors != nil && len(itemErrors) != len(items) {
+			batchErr = fmt.Errorf("batch of %d items returned %d item errors", len(items), len(itemErrors))
+		}
+		if batchErr != nil {
+			return batchErr
+		}
+		return errors.Join(itemErrors...)
+	}
+	batchTask.onFinish = func(err error) {
+		switch err.(type) {
+		case TaskPanicError, TaskTimeoutError:
+			// The batch didn't return its results, so its items fail with the error the runner records.
+			reportBatchResults(results, nil, err)
+		default:
+			reportBatchResults(results, itemErrors, batchErr)
+		}
+	}
+	batchTask.onDrop = func() {
+		reportBatchResults(results, nil, errors.New("runner stopped"))
+	}
+	batchTask.onDrain = func() {
+		b.lock.Lock()
+		b.drainedItems = append(b.drainedItems, items...)
+		b.lock.Unlock()
+		reportBatchResults(results, nil, errors.New("batch drained"))
+	}
+	if _, err := b.runner.addTask(batchTask); err != nil {
+		reportBatchResults(results, nil, err)
+		return err
+	}
+	return nil
+}
+
+func reportBatchResults(results []chan error, itemErrors []error, err error) {
+	for i, result := range results {
+		if err == nil && itemErrors != nil {
+			result <- itemErrors[i]
+		} else {
+			result <- err
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package parallel
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatcherBatchSize(t *testing.T) {
+	// Create new batcher, processing batches of 3 items
+	const count = 10
+	var batches [][]int
+	var lock sync.Mutex
+	batcher := NewBatcher[int](2, 5, false, 3, 0, func(_ int, items []int) ([]error, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		batches = append(batches, items)
+		itemErrors := make([]error, len(items))
+		for i, item := range items {
+			if item%4 == 0 {
+				itemErrors[i] = fmt.Errorf("item %d failed", item)
+			}
+		}
+		return itemErrors, nil
+	})
+	results := make([]<-chan error, count)
+	for i := 0; i < count; i++ {
+		result, err := batcher.AddItem(i)
+		require.NoError(t, err)
+		results[i] = result
+	}
+
+	// Done flushes the last, partial batch
+	batcher.Done()
+	batcher.Run()
+	assert.Len(t, batches, 4)
+	for _, batch := range batches {
+		assert.LessOrEqual(t, len(batch), 3)
+	}
+	for i, result := range results {
+		if i%4 == 0 {
+			assert.EqualError(t, <-result, fmt.Sprintf("item %d failed", i))
+		} else {
+			assert.NoError(t, <-result)
+		}
+	}
+	assert.Len(t, batcher.Errors(), 3)
+}
+
+func TestBatcherFlushInterval(t *testing.T) {
+	// Create new running batcher, with a batch size that is never reached
+	batcher := NewBatcher[string](1, 1, false, 100, 10*time.Millisecond, func(_ int, items []string) ([]error, error) {
+		return nil, nil
+	})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		batcher.Run()
+	}()
+
+	// Expect the items to be processed once the interval passes
+	result, err := batcher.AddItem("a")
+	require.NoError(t, err)
+	select {
+	case err = <-result:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "the batch wasn't flushed")
+	}
+	batcher.Done()
+	wg.Wait()
+	assert.Equal(t, uint32(1), batcher.Stats().Succeeded)
+}
+
+func TestBatcherBatchError(t *testing.T) {
+	// A batch error fails all the items of the batch
+	batcher := NewBatcher[int](1, 1, false, 2, 0, func(_ int, items []int) ([]error, error) {
+		if items[0] == 0 {
+			return nil, errTest
+		}
+		// Invalid number of item errors
+		return []error{nil}, nil
+	})
+	var results []<-chan error
+	for i := 0; i < 4; i++ {
+		result, err := batcher.AddItem(i)
+		require.NoError(t, err)
+		results = append(results, result)
+		if i == 1 {
+			go batcher.Run()
+		}
+	}
+	batcher.Done()
+	assert.ErrorIs(t, <-results[0], errTest)
+	assert.ErrorIs(t, <-results[1], errTest)
+	assert.EqualError(t, <-results[2], "batch of 2 items returned 1 item errors")
+	assert.EqualError(t, <-results[3], "batch of 2 items returned 1 item errors")
+}
+
+func TestBatcherCancel(t *testing.T) {
+	// Create new batcher with a queued batch and a partial batch
+	batcher := NewBatcher[int](1, 2, false, 2, 0, func(_ int, items []int) ([]error, error) {
+		return nil, nil
+	})
+	var results []<-chan error
+	for i := 0; i < 3; i++ {
+		result, err := batcher.AddItem(i)
+		require.NoError(t, err)
+		results = append(results, result)
+	}
+
+	// Expect all the items to fail when the batcher is cancelled before running
+	batcher.Cancel(false)
+	for _, result := range results {
+		assert.EqualError(t, <-result, "runner stopped")
+	}
+	_, err := batcher.AddItem(3)
+	assert.Error(t, err)
+}
+
+func TestBatcherDrain(t *testing.T) {
+	// Create new paused batcher with queued batches and a partial batch
+	var processed []int
+	batcher := NewBatcher[int](1, 2, false, 2, 0, func(_ int, items []int) ([]error, error) {
+		processed = append(processed, items...)
+		return nil, nil
+	})
+	batcher.Pause()
+	var results []<-chan error
+	for i := 0; i < 5; i++ {
+		result, err := batcher.AddItem(i)
+		require.NoError(t, err)
+		results = append(results, result)
+	}
+
+	// Expect the items of all the batches to be drained in order, and to receive an error
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, batcher.Drain())
+	for _, result := range results {
+		assert.EqualError(t, <-result, "batch drained")
+	}
+	assert.Empty(t, batcher.Drain())
+
+	batcher.Resume()
+	batcher.Done()
+	batcher.Run()
+	assert.Empty(t, processed)
+}
+
+func TestBatcherPanic(t *testing.T) {
+	batcher := NewBatcher[int](1, 1, false, 1, 0, func(_ int, items []int) ([]error, error) {
+		panic("batch failed")
+	})
+	result, err := batcher.AddItem(0)
+	require.NoError(t, err)
+	batcher.Done()
+	batcher.Run()
+	itemErr := <-result
+	var panicError TaskPanicError
+	if assert.True(t, errors.As(itemErr, &panicError)) {
+		assert.Equal(t, "batch failed", panicError.Value)
+		assert.NotEmpty(t, panicError.Stack)
+	}
+	assert.Equal(t, batcher.Errors()[0], itemErr)
+}
@@ -0,0 +1,246 @@
+package parallel
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DependencyFailedError is the error of a task that was skipped, since one of its dependencies failed or was skipped.
+type DependencyFailedError struct {
+	TaskId     string
+	Dependency string
+}
+
+func (e DependencyFailedError) Error() string {
+	return fmt.Sprintf("task '%s' was skipped since its dependency '%s' failed", e.TaskId, e.Dependency)
+}
+
+type dagTask struct {
+	id        string
+	run       TaskFunc
+	dependsOn []string
+	// The tasks depending on this task.
+	dependents []*dagTask
+	// The number of dependencies that didn't finish successfully yet.
+	pendingDependencies int
+	// True when the task finished or was skipped.
+	resolved bool
+}
+
+// DagRunner executes tasks with dependencies between them.
+// A task is passed to the runner's threads only after all its dependencies finished successfully.
+// If a dependency fails, the task is skipped with a DependencyFailedError.
+type DagRunner struct {
+	// The maximum number of threads running in parallel.
+	maxParallel int
+	// If true, the runner will be cancelled on the first error thrown from a task.
+	failFast bool
+	// The tasks keyed by their id.
+	tasks map[string]*dagTask
+	// The task ids in the order they were added.
+	order []string
+	// The number of tasks that didn't finish and weren't skipped.
+	unresolved int
+	// The runner executing the tasks. Created when Run is invoked.
+	runner *runner
+	// True when Cancel was invoked, so the tasks are not executed even if Run is invoked after it.
+	cancelled bool
+	// A map of errors keyed by the task id.
+	errors map[string]error
+	// A lock on the tasks and errors.
+	lock sync.Mutex
+}
+
+// Create a new DAG runner.
+// maxParallel - number of go routines for task processing, maxParallel always will be a positive number.
+// failFast - is set to true the will stop on first error.
+func NewDagRunner(maxParallel int, failFast bool) *DagRunner {
+	return &DagRunner{
+		maxParallel: maxParallel,
+		failFast:    failFast,
+		tasks:       make(map[string]*dagTask),
+		errors:      make(map[string]error),
+	}
+}
+
+// AddTask adds a task that will be executed after all the tasks it depends on finished successfully.
+// Dependencies may refer to tasks that weren't added yet, but must be added before Run is invoked.
+// id - a unique identifier of the task.
+// dependsOn - the ids of the tasks that must finish before t starts.
+// Returns an error if the id is already in use, or if the dependencies create a cycle.
+func (d *DagRunner) AddTask(id string, t TaskFunc, dependsOn ...string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.runner != nil {
+		return errors.New("tasks can't be added after the DAG runner started")
+	}
+	if _, exists := d.tasks[id]; exists {
+		return fmt.Errorf("task '%s' already exists", id)
+	}
+	for _, dependency := range dependsOn {
+		if path := d.findPath(dependency, id, map[string]bool{}); path != nil {
+			return fmt.Errorf("task '%s' can't depend on '%s', since it creates a dependency cycle: %s", id, dependency, strings.Join(append([]string{id}, path...), " -> "))
+		}
+	}
+	d.tasks[id] = &dagTask{id: id, run: t, dependsOn: dependsOn}
+	d.order = append(d.order, id)
+	return nil
+}
+
+// Run executes the tasks and waits until all the tasks are finished or skipped.
+// Returns an error if a task depends on a task that wasn't added.
+// Notice: Run() is a blocking operation.
+func (d *DagRunner) Run() error {
+	d.lock.Lock()
+	if d.runner != nil {
+		d.lock.Unlock()
+		return errors.New("the DAG runner already started")
+	}
+	for _, id := range d.order {
+		for _, dependency := range d.tasks[id].dependsOn {
+			if _, exists := d.tasks[dependency]; !exists {
+				d.lock.Unlock()
+				return fmt.Errorf("task '%s' depends on a task that doesn't exist: '%s'", id, dependency)
+			}
+		}
+	}
+
+	// The capacity allows adding all the tasks without blocking, so tasks can be added from the runner's threads.
+	d.runner = NewRunner(d.maxParallel, uint(len(d.tasks)), d.failFast)
+	if d.cancelled {
+		// The runner rejects the tasks, so they are resolved as stopped.
+		d.runner.Cancel(true)
+	}
+	for _, id := range d.order {
+		task := d.tasks[id]
+		task.pendingDependencies = len(task.dependsOn)
+		for _, dependency := range task.dependsOn {
+			d.tasks[dependency].dependents = append(d.tasks[dependency].dependents, task)
+		}
+	}
+	d.unresolved = len(d.tasks)
+	if d.unresolved == 0 {
+		d.runner.Done()
+	}
+	for _, id := range d.order {
+		if task := d.tasks[id]; task.pendingDependencies == 0 {
+			d.submit(task)
+		}
+	}
+	d.lock.Unlock()
+
+	d.runner.Run()
+
+	// Tasks that weren't executed, since the runner was cancelled.
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, id := range d.order {
+		if task := d.tasks[id]; !task.resolved {
+			d.resolve(task, errors.New("runner stopped"))
+		}
+	}
+	return nil
+}
+
+// Cancel stops the DAG runner. Tasks that didn't start will not be executed.
+// If Run wasn't invoked yet, no task will be executed by it.
+func (d *DagRunner) Cancel() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.cancelled = true
+	if d.runner != nil {
+		d.runner.Cancel(true)
+	}
+}
+
+// Errors returns a map of errors keyed by the task id.
+// Skipped tasks are mapped to a DependencyFailedError.
+func (d *DagRunner) Errors() map[string]error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	errs := make(map[string]error, len(d.errors))
+	for id, err := range d.errors {
+		errs[id] = err
+	}
+	return errs
+}
+
+// Pass the task to the runner's threads. Must be called while holding the lock.
+func (d *DagRunner) submit(task *dagTask) {
+	_, err := d.runner.AddTaskWithError(func(threadId int) error {
+		err := task.run(threadId)
+		if err == nil {
+			d.onTaskDone(task, nil)
+		}
+		return err
+	}, func(err error) {
+		// Failed tasks are resolved by the error handler, which also receives the panics and the timeouts of the tasks.
+		d.onTaskDone(task, err)
+	})
+	if err != nil {
+		d.resolve(task, err)
+	}
+}
+
+func (d *DagRunner) onTaskDone(task *dagTask, err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.resolve(task, err)
+	if err != nil {
+		d.skipDependents(task)
+		return
+	}
+	for _, dependent := range task.dependents {
+		dependent.pendingDependencies--
+		if dependent.pendingDependencies == 0 && !dependent.resolved {
+			d.submit(dependent)
+		}
+	}
+}
+
+// Skip the tasks depending on the failed task, recursively. Must be called while holding the lock.
+func (d *DagRunner) skipDependents(failed *dagTask) {
+	for _, dependent := range failed.dependents {
+		if dependent.resolved {
+			continue
+		}
+		d.resolve(dependent, DependencyFailedError{TaskId: dependent.id, Dependency: failed.id})
+		d.skipDependents(dependent)
+	}
+}
+
+// Mark the task as finished or skipped. Must be called while holding the lock.
+func (d *DagRunner) resolve(task *dagTask, err error) {
+	task.resolved = true
+	if err != nil {
+		d.errors[task.id] = err
+	}
+	d.unresolved--
+	if d.unresolved == 0 {
+		d.runner.Done()
+	}
+}
+
+// Returns the dependency path from the task 'from' to the task 'to', or nil if 'from' doesn't depend on 'to'.
+// Must be called while holding the lock.
+func (d *DagRunner) findPath(from, to string, visited map[string]bool) []string {
+	if from == to {
+		return []string{to}
+	}
+	if visited[from] {
+		return nil
+	}
+	visited[from] = true
+	task, exists := d.tasks[from]
+	if !exists {
+		return nil
+	}
+	for _, dependency := range task.dependsOn {
+		if path := d.findPath(dependency, to, visited); path != nil {
+			return append([]string{from}, path...)
+		}
+	}
+	return nil
+}
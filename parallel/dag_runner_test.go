@@ -0,0 +1,140 @@
+package parallel
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDagRunnerOrder(t *testing.T) {
+	// Create a DAG: "dir" -> "file1", "file2" -> "build-info"
+	dagRunner := NewDagRunner(3, false)
+	var finished []string
+	var finishedLock sync.Mutex
+	createTask := func(id string) TaskFunc {
+		return func(int) error {
+			finishedLock.Lock()
+			defer finishedLock.Unlock()
+			finished = append(finished, id)
+			return nil
+		}
+	}
+	// Dependencies may be added before the tasks they refer to
+	assert.NoError(t, dagRunner.AddTask("build-info", createTask("build-info"), "file1", "file2"))
+	assert.NoError(t, dagRunner.AddTask("file1", createTask("file1"), "dir"))
+	assert.NoError(t, dagRunner.AddTask("file2", createTask("file2"), "dir"))
+	assert.NoError(t, dagRunner.AddTask("dir", createTask("dir")))
+
+	assert.NoError(t, dagRunner.Run())
+	assert.Empty(t, dagRunner.Errors())
+	assert.Len(t, finished, 4)
+	assert.Equal(t, "dir", finished[0])
+	assert.ElementsMatch(t, []string{"file1", "file2"}, finished[1:3])
+	assert.Equal(t, "build-info", finished[3])
+}
+
+func TestDagRunnerSkipDependents(t *testing.T) {
+	// Create a DAG: "a" -> "b" -> "c", "d"
+	dagRunner := NewDagRunner(2, false)
+	executed := make(map[string]bool)
+	var executedLock sync.Mutex
+	createTask := func(id string, err error) TaskFunc {
+		return func(int) error {
+			executedLock.Lock()
+			defer executedLock.Unlock()
+			executed[id] = true
+			return err
+		}
+	}
+	assert.NoError(t, dagRunner.AddTask("a", createTask("a", errTest)))
+	assert.NoError(t, dagRunner.AddTask("b", createTask("b", nil), "a"))
+	assert.NoError(t, dagRunner.AddTask("c", createTask("c", nil), "b"))
+	assert.NoError(t, dagRunner.AddTask("d", createTask("d", nil)))
+
+	assert.NoError(t, dagRunner.Run())
+	assert.Equal(t, map[string]bool{"a": true, "d": true}, executed)
+	assert.Equal(t, map[string]error{
+		"a": errTest,
+		"b": DependencyFailedError{TaskId: "b", Dependency: "a"},
+		"c": DependencyFailedError{TaskId: "c", Dependency: "b"},
+	}, dagRunner.Errors())
+}
+
+func TestDagRunnerPanic(t *testing.T) {
+	// Create a DAG: "a" -> "b", where "a" panics
+	dagRunner := NewDagRunner(2, false)
+	assert.NoError(t, dagRunner.AddTask("a", func(int) error {
+		panic("a panicked")
+	}))
+	assert.NoError(t, dagRunner.AddTask("b", func(int) error {
+		return nil
+	}, "a"))
+
+	// Expect the panic to resolve "a" and to skip "b", instead of blocking Run
+	assert.NoError(t, dagRunner.Run())
+	errs := dagRunner.Errors()
+	assert.IsType(t, TaskPanicError{}, errs["a"])
+	assert.Equal(t, DependencyFailedError{TaskId: "b", Dependency: "a"}, errs["b"])
+}
+
+func TestDagRunnerCycle(t *testing.T) {
+	dagRunner := NewDagRunner(1, false)
+	noop := func(int) error { return nil }
+	assert.NoError(t, dagRunner.AddTask("a", noop, "c"))
+	assert.NoError(t, dagRunner.AddTask("b", noop, "a"))
+	assert.EqualError(t, dagRunner.AddTask("c", noop, "b"), "task 'c' can't depend on 'b', since it creates a dependency cycle: c -> b -> a -> c")
+	assert.EqualError(t, dagRunner.AddTask("d", noop, "d"), "task 'd' can't depend on 'd', since it creates a dependency cycle: d -> d")
+	assert.EqualError(t, dagRunner.AddTask("a", noop), "task 'a' already exists")
+}
+
+func TestDagRunnerUnknownDependency(t *testing.T) {
+	dagRunner := NewDagRunner(1, false)
+	assert.NoError(t, dagRunner.AddTask("a", func(int) error { return nil }, "b"))
+	assert.EqualError(t, dagRunner.Run(), "task 'a' depends on a task that doesn't exist: 'b'")
+}
+
+func TestDagRunnerFailFast(t *testing.T) {
+	// Create a DAG with a failing root and an independent chain
+	dagRunner := NewDagRunner(1, true)
+	noop := func(int) error { return nil }
+	assert.NoError(t, dagRunner.AddTask("a", func(int) error { return errTest }))
+	assert.NoError(t, dagRunner.AddTask("b", noop, "a"))
+	assert.NoError(t, dagRunner.AddTask("c", noop))
+	assert.NoError(t, dagRunner.AddTask("d", noop, "c"))
+
+	// All the tasks are resolved, even though the runner stopped
+	assert.NoError(t, dagRunner.Run())
+	errs := dagRunner.Errors()
+	assert.Equal(t, errTest, errs["a"])
+	assert.Equal(t, DependencyFailedError{TaskId: "b", Dependency: "a"}, errs["b"])
+	assert.EqualError(t, errs["d"], "runner stopped")
+}
+
+func TestDagRunnerCancelBeforeRun(t *testing.T) {
+	// Create a DAG, and cancel it before running it
+	dagRunner := NewDagRunner(2, false)
+	executed := false
+	task := func(int) error {
+		executed = true
+		return nil
+	}
+	assert.NoError(t, dagRunner.AddTask("a", task))
+	assert.NoError(t, dagRunner.AddTask("b", task, "a"))
+	dagRunner.Cancel()
+
+	// No task is executed, and all the tasks are resolved as stopped
+	assert.NoError(t, dagRunner.Run())
+	assert.False(t, executed)
+	errs := dagRunner.Errors()
+	assert.Len(t, errs, 2)
+	assert.EqualError(t, errs["a"], "runner stopped")
+	assert.EqualError(t, errs["b"], "runner stopped")
+}
+
+func TestDagRunnerEmpty(t *testing.T) {
+	dagRunner := NewDagRunner(1, false)
+	assert.NoError(t, dagRunner.Run())
+	assert.Empty(t, dagRunner.Errors())
+	assert.Error(t, dagRunner.Run())
+}
@@ -0,0 +1,134 @@
+package parallel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TaskPanicError is the error of a task that panicked.
+type TaskPanicError struct {
+	TaskId int
+	// The value passed to panic.
+	Value interface{}
+	// The stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+func (e TaskPanicError) Error() string {
+	return fmt.Sprintf("task %d panicked: %v", e.TaskId, e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e TaskPanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// TaskTimeoutError is the error of a task that didn't finish within its timeout.
+type TaskTimeoutError struct {
+	TaskId  int
+	Timeout time.Duration
+}
+
+func (e TaskTimeoutError) Error() string {
+	return fmt.Sprintf("task %d timed out after %s", e.TaskId, e.Timeout)
+}
+
+// Unwrap returns context.DeadlineExceeded, so the error can be checked with errors.Is.
+func (e TaskTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// TaskFailure is the failure of a single task, after all its attempts.
+type TaskFailure struct {
+	TaskId int
+	// The number of times the task was executed.
+	Attempt int
+	// The duration of the last attempt.
+	Duration time.Duration
+	// The label of a task added with AddTaskWithLabel, empty for other tasks.
+	Label string
+	// The error returned from the last attempt.
+	Err error
+}
+
+func (f TaskFailure) Error() string {
+	name := fmt.Sprintf("task %d", f.TaskId)
+	if f.Label != "" {
+		name += fmt.Sprintf(" (%s)", f.Label)
+	}
+	return fmt.Sprintf("%s failed: %v", name, f.Err)
+}
+
+func (f TaskFailure) Unwrap() error {
+	return f.Err
+}
+
+// RunnerError is the error of a runner with failed tasks (see @Err()).
+// It can be checked with errors.Is and errors.As like errors joined by errors.Join, and the details of each failure
+// can be extracted with errors.As(err, &TaskFailure{}).
+type RunnerError struct {
+	// The failures of the tasks, ordered by the task number.
+	Failures []TaskFailure
+}
+
+func (e *RunnerError) Error() string {
+	messages := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		messages[i] = failure.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Unwrap returns the failures of the tasks.
+func (e *RunnerError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, failure := range e.Failures {
+		errs[i] = failure
+	}
+	return errs
+}
+
+// Summary returns a human-readable report of the failures, for CLI output.
+func (e *RunnerError) Summary() string {
+	var summary strings.Builder
+	if len(e.Failures) == 1 {
+		summary.WriteString("1 task failed:")
+	} else {
+		summary.WriteString(fmt.Sprintf("%d tasks failed:", len(e.Failures)))
+	}
+	for _, failure := range e.Failures {
+		summary.WriteString(fmt.Sprintf("\n  %s (attempts: %d, duration: %s)", failure.Error(), failure.Attempt, failure.Duration))
+	}
+	return summary.String()
+}
+
+type taskFailureJson struct {
+	TaskId   int    `json:"taskId"`
+	Attempt  int    `json:"attempt"`
+	Duration string `json:"duration"`
+	Label    string `json:"label,omitempty"`
+	Error    string `json:"error"`
+}
+
+// MarshalJSON encodes the failures, for machine-readable reports.
+func (e *RunnerError) MarshalJSON() ([]byte, error) {
+	failures := make([]taskFailureJson, len(e.Failures))
+	for i, failure := range e.Failures {
+		failures[i] = taskFailureJson{
+			TaskId:   failure.TaskId,
+			Attempt:  failure.Attempt,
+			Duration: failure.Duration.String(),
+			Label:    failure.Label,
+			Error:    failure.Err.Error(),
+		}
+	}
+	return json.Marshal(struct {
+		Failures []taskFailureJson `json:"failures"`
+	}{Failures: failures})
+}
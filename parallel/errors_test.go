@@ -0,0 +1,85 @@
+package parallel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunnerErr(t *testing.T) {
+	// Create new runner with failing tasks
+	runner := NewRunner(2, 5, false)
+	_, err := runner.AddTaskWithLabel("upload a.zip", func(int) error { return errTest })
+	assert.NoError(t, err)
+	_, err = runner.AddTask(func(int) error { return nil })
+	assert.NoError(t, err)
+	_, err = runner.AddTaskWithRetry(func(int) error { return TaskTimeoutError{TaskId: 2, Timeout: time.Second} }, RetryPolicy{MaxAttempts: 2})
+	assert.NoError(t, err)
+	runner.Done()
+	runner.Run()
+
+	// Expect the failures ordered by the task number
+	var runnerError *RunnerError
+	require.ErrorAs(t, runner.Err(), &runnerError)
+	require.Len(t, runnerError.Failures, 2)
+	assert.Equal(t, 0, runnerError.Failures[0].TaskId)
+	assert.Equal(t, 1, runnerError.Failures[0].Attempt)
+	assert.Equal(t, "upload a.zip", runnerError.Failures[0].Label)
+	assert.Equal(t, 2, runnerError.Failures[1].TaskId)
+	assert.Equal(t, 2, runnerError.Failures[1].Attempt)
+	assert.Empty(t, runnerError.Failures[1].Label)
+
+	// The errors of the tasks can be checked like joined errors
+	assert.ErrorIs(t, runner.Err(), errTest)
+	assert.ErrorIs(t, runner.Err(), context.DeadlineExceeded)
+	var failure TaskFailure
+	require.ErrorAs(t, runner.Err(), &failure)
+	assert.Equal(t, 0, failure.TaskId)
+	assert.EqualError(t, runner.Err(), "task 0 (upload a.zip) failed: some error\ntask 2 failed: task 2 timed out after 1s")
+
+	// Errors returns a copy
+	errs := runner.Errors()
+	assert.Len(t, errs, 2)
+	delete(errs, 0)
+	assert.Len(t, runner.Errors(), 2)
+}
+
+func TestRunnerErrNoFailures(t *testing.T) {
+	runner := NewRunner(1, 1, false)
+	_, err := runner.AddTask(func(int) error { return nil })
+	assert.NoError(t, err)
+	runner.Done()
+	runner.Run()
+	assert.NoError(t, runner.Err())
+}
+
+func TestRunnerErrorSummary(t *testing.T) {
+	runnerError := &RunnerError{Failures: []TaskFailure{
+		{TaskId: 1, Attempt: 3, Duration: 1500 * time.Millisecond, Label: "a.zip", Err: errTest},
+		{TaskId: 4, Attempt: 1, Duration: time.Second, Err: errors.New("other error")},
+	}}
+	assert.Equal(t, "2 tasks failed:\n"+
+		"  task 1 (a.zip) failed: some error (attempts: 3, duration: 1.5s)\n"+
+		"  task 4 failed: other error (attempts: 1, duration: 1s)", runnerError.Summary())
+
+	runnerError.Failures = runnerError.Failures[:1]
+	assert.Equal(t, "1 task failed:\n  task 1 (a.zip) failed: some error (attempts: 3, duration: 1.5s)", runnerError.Summary())
+}
+
+func TestRunnerErrorJson(t *testing.T) {
+	runnerError := &RunnerError{Failures: []TaskFailure{
+		{TaskId: 1, Attempt: 3, Duration: 1500 * time.Millisecond, Label: "a.zip", Err: errTest},
+		{TaskId: 4, Attempt: 1, Duration: time.Second, Err: errors.New("other error")},
+	}}
+	content, err := json.Marshal(runnerError)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"failures": [
+		{"taskId": 1, "attempt": 3, "duration": "1.5s", "label": "a.zip", "error": "some error"},
+		{"taskId": 4, "attempt": 1, "duration": "1s", "error": "other error"}
+	]}`, string(content))
+}
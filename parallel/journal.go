@@ -0,0 +1,226 @@
+package parallel
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	ioutils "github.com/jfrog/gofrog/io"
+)
+
+const (
+	journalOpAdd  = "add"
+	journalOpDone = "done"
+)
+
+// TaskDescriptor is a serializable description of a task, used to recreate the task when a run is resumed.
+type TaskDescriptor struct {
+	// A unique key of the task, such as the path of the file the task transfers.
+	Key string `json:"key"`
+	// The type of the task, used to find the factory that recreates the task (see @RegisterTaskFactory()).
+	Type string `json:"type"`
+	// Task specific data, required to recreate the task.
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// TaskFactory recreates a task from its descriptor.
+type TaskFactory func(descriptor TaskDescriptor) (ContextTaskFunc, error)
+
+type journalRecord struct {
+	Op   string          `json:"op"`
+	Task *TaskDescriptor `json:"task,omitempty"`
+	Key  string          `json:"key,omitempty"`
+}
+
+// Journal is an append-only file, recording the tasks added to a runner and the tasks that finished successfully.
+// Each line in the file is a JSON record.
+type Journal struct {
+	file *os.File
+	// The descriptors of the added tasks that didn't finish successfully, keyed by the task key.
+	unfinished map[string]TaskDescriptor
+	// The keys of the unfinished tasks, in the order they were added.
+	order []string
+	// A lock on the file and the unfinished tasks.
+	lock sync.Mutex
+}
+
+// OpenJournal opens the journal file, or creates it if it doesn't exist.
+// The records of an existing journal are loaded, so the unfinished tasks can be resumed (see @NewResumeRunner()).
+func OpenJournal(path string) (journal *Journal, err error) {
+	journal = &Journal{unfinished: make(map[string]TaskDescriptor)}
+	partialLineOffset, err := journal.load(path)
+	if err != nil {
+		return nil, err
+	}
+	if partialLineOffset >= 0 {
+		// Remove the partial last line, so the next record starts in a new line.
+		if err = os.Truncate(path, partialLineOffset); err != nil {
+			return nil, fmt.Errorf("failed to truncate journal '%s': %w", path, err)
+		}
+	}
+	journal.file, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal '%s': %w", path, err)
+	}
+	return journal, nil
+}
+
+// Unfinished returns the descriptors of the tasks that were added and didn't finish successfully, in the order they
+// were added.
+func (j *Journal) Unfinished() []TaskDescriptor {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	descriptors := make([]TaskDescriptor, 0, len(j.unfinished))
+	for _, key := range j.order {
+		if descriptor, ok := j.unfinished[key]; ok {
+			descriptors = append(descriptors, descriptor)
+		}
+	}
+	return descriptors
+}
+
+func (j *Journal) Close() error {
+	return j.file.Close()
+}
+
+func (j *Journal) recordAdded(descriptor TaskDescriptor) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if err := j.write(journalRecord{Op: journalOpAdd, Task: &descriptor}); err != nil {
+		return err
+	}
+	j.apply(journalRecord{Op: journalOpAdd, Task: &descriptor})
+	return nil
+}
+
+func (j *Journal) recordDone(key string) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if err := j.write(journalRecord{Op: journalOpDone, Key: key}); err != nil {
+		return err
+	}
+	j.apply(journalRecord{Op: journalOpDone, Key: key})
+	return nil
+}
+
+// Write a record to the file. Must be called while holding the lock.
+func (j *Journal) write(record journalRecord) error {
+	content, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	// A single write, so a killed process leaves at most one partial line at the end of the file.
+	if _, err = j.file.Write(append(content, '\n')); err != nil {
+		return fmt.Errorf("failed to write to journal '%s': %w", j.file.Name(), err)
+	}
+	return nil
+}
+
+// Apply a record to the unfinished tasks. Must be called while holding the lock.
+func (j *Journal) apply(record journalRecord) {
+	switch record.Op {
+	case journalOpAdd:
+		if _, exists := j.unfinished[record.Task.Key]; !exists {
+			j.order = append(j.order, record.Task.Key)
+		}
+		j.unfinished[record.Task.Key] = *record.Task
+	case journalOpDone:
+		delete(j.unfinished, record.Key)
+	}
+}
+
+// Load the records of the journal file.
+// Returns the offset of the partial last line, written when the process was killed, or -1 if there is none.
+func (j *Journal) load(path string) (partialLineOffset int64, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return -1, nil
+		}
+		return -1, fmt.Errorf("failed to open journal '%s': %w", path, err)
+	}
+	defer ioutils.Close(file, &err)
+	reader := bufio.NewReader(file)
+	var offset int64
+	for lineNumber := 1; ; lineNumber++ {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return -1, fmt.Errorf("failed to read journal '%s': %w", path, readErr)
+		}
+		if errors.Is(readErr, io.EOF) {
+			// Each record is written with its line break, so a last line without a line break is partial.
+			if len(line) > 0 {
+				return offset, nil
+			}
+			return -1, nil
+		}
+		offset += int64(len(line))
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var record journalRecord
+			if err = json.Unmarshal(line, &record); err != nil || !record.isValid() {
+				return -1, fmt.Errorf("invalid record in line %d of journal '%s'", lineNumber, path)
+			}
+			j.apply(record)
+		}
+	}
+}
+
+func (record *journalRecord) isValid() bool {
+	switch record.Op {
+	case journalOpAdd:
+		return record.Task != nil
+	case journalOpDone:
+		return true
+	}
+	return false
+}
+
+// ResumeRunner is a runner that replays the tasks that didn't finish in previous runs, as recorded in its journal.
+type ResumeRunner struct {
+	*runner
+	// The factories recreating the tasks, keyed by the task type.
+	factories map[string]TaskFactory
+}
+
+// Create a new resume runner, recording its tasks in the journal.
+// maxParallel - number of go routines for task processing, maxParallel always will be a positive number.
+// capacity - number of tasks that can be added until a free processing goroutine is needed.
+// failFast - is set to true the will stop on first error.
+func NewResumeRunner(journal *Journal, maxParallel int, capacity uint, failFast bool) *ResumeRunner {
+	rr := &ResumeRunner{runner: NewRunner(maxParallel, capacity, failFast), factories: make(map[string]TaskFactory)}
+	rr.SetJournal(journal)
+	return rr
+}
+
+// RegisterTaskFactory registers the factory that recreates the tasks of the given type.
+func (rr *ResumeRunner) RegisterTaskFactory(taskType string, factory TaskFactory) {
+	rr.factories[taskType] = factory
+}
+
+// ReplayUnfinished adds the unfinished tasks recorded in the journal, using the registered task factories.
+// Like AddTask, ReplayUnfinished blocks while the queue is full, so it should run in parallel to Run.
+// Returns the number of tasks added.
+func (rr *ResumeRunner) ReplayUnfinished() (int, error) {
+	descriptors := rr.journal.Unfinished()
+	for i, descriptor := range descriptors {
+		factory, ok := rr.factories[descriptor.Type]
+		if !ok {
+			return i, fmt.Errorf("no task factory is registered for the type '%s' of task '%s'", descriptor.Type, descriptor.Key)
+		}
+		t, err := factory(descriptor)
+		if err != nil {
+			return i, fmt.Errorf("failed to recreate task '%s': %w", descriptor.Key, err)
+		}
+		resumedDescriptor := descriptor
+		// The task is already recorded in the journal.
+		if _, err = rr.addTask(&task{run: t, descriptor: &resumedDescriptor, resumed: true}); err != nil {
+			return i, err
+		}
+	}
+	return len(descriptors), nil
+}
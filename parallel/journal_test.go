@@ -0,0 +1,140 @@
+package parallel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const uploadTaskType = "upload"
+
+var _ Runner = (*ResumeRunner)(nil)
+
+func TestResumeRunner(t *testing.T) {
+	journalPath := filepath.Join(t.TempDir(), "journal")
+
+	// First run - "file0" succeeds, and "file1" fails and stops the runner before "file2" and "file3" start
+	journal, err := OpenJournal(journalPath)
+	require.NoError(t, err)
+	runner := NewRunner(1, 4, true)
+	runner.SetJournal(journal)
+	for i := 0; i < 4; i++ {
+		fail := i == 1
+		_, err = runner.AddDescribedTask(createUploadDescriptor(t, i), func(context.Context, int) error {
+			if fail {
+				return errTest
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+	runner.Run()
+	assert.NoError(t, journal.Close())
+
+	// Second run - resume the unfinished tasks
+	journal, err = OpenJournal(journalPath)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, journal.Close())
+	}()
+	assert.Equal(t, []TaskDescriptor{createUploadDescriptor(t, 1), createUploadDescriptor(t, 2), createUploadDescriptor(t, 3)}, journal.Unfinished())
+
+	resumeRunner := NewResumeRunner(journal, 2, 4, false)
+	var uploaded []string
+	var uploadedLock sync.Mutex
+	resumeRunner.RegisterTaskFactory(uploadTaskType, func(descriptor TaskDescriptor) (ContextTaskFunc, error) {
+		var path string
+		if err := json.Unmarshal(descriptor.Payload, &path); err != nil {
+			return nil, err
+		}
+		return func(context.Context, int) error {
+			uploadedLock.Lock()
+			defer uploadedLock.Unlock()
+			uploaded = append(uploaded, path)
+			return nil
+		}, nil
+	})
+	resumed, err := resumeRunner.ReplayUnfinished()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, resumed)
+	resumeRunner.Done()
+	resumeRunner.Run()
+	assert.ElementsMatch(t, []string{"file1", "file2", "file3"}, uploaded)
+	assert.Empty(t, journal.Unfinished())
+
+	// The resumed tasks are recorded as done, and not added again
+	reopened, err := OpenJournal(journalPath)
+	require.NoError(t, err)
+	assert.Empty(t, reopened.Unfinished())
+	assert.NoError(t, reopened.Close())
+}
+
+func TestResumeRunnerPause(t *testing.T) {
+	journal, err := OpenJournal(filepath.Join(t.TempDir(), "journal"))
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, journal.Close())
+	}()
+	resumeRunner := NewResumeRunner(journal, 1, 1, false)
+
+	// Resume un-pauses the runner, and doesn't replay the journal
+	resumeRunner.Pause()
+	assert.True(t, resumeRunner.IsPaused())
+	resumeRunner.Resume()
+	assert.False(t, resumeRunner.IsPaused())
+}
+
+func TestResumeRunnerMissingFactory(t *testing.T) {
+	journalPath := filepath.Join(t.TempDir(), "journal")
+	journal, err := OpenJournal(journalPath)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, journal.Close())
+	}()
+	assert.NoError(t, journal.recordAdded(createUploadDescriptor(t, 0)))
+
+	resumeRunner := NewResumeRunner(journal, 1, 1, false)
+	resumed, err := resumeRunner.ReplayUnfinished()
+	assert.EqualError(t, err, "no task factory is registered for the type 'upload' of task 'file0'")
+	assert.Zero(t, resumed)
+}
+
+func TestOpenJournalPartialLastLine(t *testing.T) {
+	journalPath := filepath.Join(t.TempDir(), "journal")
+	content := `{"op":"add","task":{"key":"a","type":"upload"}}
+{"op":"add","task":{"key":"b","type":"upload"}}
+{"op":"done","key":"a"}
+{"op":"done","ke`
+	require.NoError(t, os.WriteFile(journalPath, []byte(content), 0600))
+
+	// A partial last line is ignored
+	journal, err := OpenJournal(journalPath)
+	require.NoError(t, err)
+	assert.Equal(t, []TaskDescriptor{{Key: "b", Type: uploadTaskType}}, journal.Unfinished())
+
+	// The partial last line is removed, so the next record starts in a new line
+	assert.NoError(t, journal.recordAdded(TaskDescriptor{Key: "c", Type: uploadTaskType}))
+	assert.NoError(t, journal.Close())
+	journal, err = OpenJournal(journalPath)
+	require.NoError(t, err)
+	assert.Equal(t, []TaskDescriptor{{Key: "b", Type: uploadTaskType}, {Key: "c", Type: uploadTaskType}}, journal.Unfinished())
+	assert.NoError(t, journal.Close())
+
+	// An invalid line in the middle of the journal is an error
+	require.NoError(t, os.WriteFile(journalPath, []byte("invalid\n"+content), 0600))
+	_, err = OpenJournal(journalPath)
+	assert.ErrorContains(t, err, "invalid record in line 1")
+}
+
+func createUploadDescriptor(t *testing.T, i int) TaskDescriptor {
+	payload, err := json.Marshal(fmt.Sprintf("file%d", i))
+	require.NoError(t, err)
+	return TaskDescriptor{Key: fmt.Sprintf("file%d", i), Type: uploadTaskType, Payload: payload}
+}
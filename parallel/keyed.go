@@ -0,0 +1,69 @@
+package parallel
+
+// Add a task that doesn't run concurrently with other tasks with the same key, such as tasks writing to the same path.
+// Tasks with the same key run in the order they were added, while tasks with different keys run in parallel.
+// Never blocks while a previous task with the same key didn't finish, since the task is held outside the queue.
+func (r *runner) AddKeyedTask(key string, t TaskFunc) (int, error) {
+	return r.AddTaskWithOptions(withoutContext(t), WithKey(key))
+}
+
+// Mark the task's key as busy. If the key is already busy, hold the task until the previous task with the same key
+// finishes, and return false.
+func (r *runner) acquireKey(t *task) bool {
+	if t.key == "" {
+		return true
+	}
+	r.keyedTasksLock.Lock()
+	defer r.keyedTasksLock.Unlock()
+	if waiting, busy := r.keyedTasks[t.key]; busy {
+		r.keyedTasks[t.key] = append(waiting, t)
+		return false
+	}
+	r.keyedTasks[t.key] = nil
+	return true
+}
+
+// Called when a keyed task will not be executed anymore. Pushes the next task with the same key to the queue, or marks
+// the key as free if there is none.
+func (r *runner) releaseKey(t *task) {
+	if t.key == "" {
+		return
+	}
+	r.keyedTasksLock.Lock()
+	waiting := r.keyedTasks[t.key]
+	if len(waiting) == 0 {
+		delete(r.keyedTasks, t.key)
+		r.keyedTasksLock.Unlock()
+		return
+	}
+	next := waiting[0]
+	waiting[0] = nil
+	r.keyedTasks[t.key] = waiting[1:]
+	r.keyedTasksLock.Unlock()
+	// Push in a different goroutine, since pushing to a full queue blocks until a thread is free.
+	go func() {
+		if !r.tasks.push(next) {
+			// The runner was cancelled, so the task will not be executed.
+			r.dropTask(next)
+		}
+	}()
+}
+
+// Called by Drain. Takes the keyed tasks waiting for a previous task with their key, without pushing them to the queue.
+// drained - the tasks taken from the queue. Their keys are marked as free, since these tasks will not be executed.
+func (r *runner) takeWaitingKeyedTasks(drained []*task) (waitingTasks []*task) {
+	r.keyedTasksLock.Lock()
+	defer r.keyedTasksLock.Unlock()
+	for _, t := range drained {
+		if t.key != "" {
+			waitingTasks = append(waitingTasks, r.keyedTasks[t.key]...)
+			delete(r.keyedTasks, t.key)
+		}
+	}
+	// The keys of the running tasks stay busy.
+	for key, waiting := range r.keyedTasks {
+		waitingTasks = append(waitingTasks, waiting...)
+		r.keyedTasks[key] = nil
+	}
+	return waitingTasks
+}
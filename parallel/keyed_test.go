@@ -0,0 +1,204 @@
+package parallel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyedTasks(t *testing.T) {
+	// Create new runner, and add tasks with a few keys
+	const keys = 3
+	const tasksPerKey = 20
+	runner := NewRunner(4, 2, false)
+	var lock sync.Mutex
+	running := make(map[string]int)
+	order := make(map[string][]int)
+	taskIds := make(map[string][]int)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer runner.Done()
+		for i := 0; i < tasksPerKey; i++ {
+			for k := 0; k < keys; k++ {
+				key := fmt.Sprintf("repo-%d", k)
+				index := i
+				taskId, err := runner.AddKeyedTask(key, func(int) error {
+					lock.Lock()
+					running[key]++
+					assert.Equal(t, 1, running[key], "tasks with the key '%s' run concurrently", key)
+					order[key] = append(order[key], index)
+					lock.Unlock()
+					time.Sleep(time.Millisecond)
+					lock.Lock()
+					running[key]--
+					lock.Unlock()
+					return nil
+				})
+				assert.NoError(t, err)
+				taskIds[key] = append(taskIds[key], taskId)
+			}
+		}
+	}()
+	runner.Run()
+	wg.Wait()
+
+	// Expect the tasks of each key to run one at a time, in the order they were added
+	for k := 0; k < keys; k++ {
+		key := fmt.Sprintf("repo-%d", k)
+		assert.Len(t, order[key], tasksPerKey)
+		for i, index := range order[key] {
+			assert.Equal(t, i, index)
+		}
+		assert.Len(t, taskIds[key], tasksPerKey)
+	}
+	assert.Equal(t, uint32(keys*tasksPerKey), runner.Stats().Succeeded)
+}
+
+func TestKeyedTasksRunInParallel(t *testing.T) {
+	// Create new runner, with tasks of different keys waiting for each other
+	runner := NewRunner(2, 2, false)
+	var started sync.WaitGroup
+	started.Add(2)
+	for _, key := range []string{"a", "b"} {
+		_, err := runner.AddKeyedTask(key, func(int) error {
+			started.Done()
+			started.Wait()
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+	runner.Done()
+	runner.Run()
+	assert.Equal(t, uint32(2), runner.Stats().Succeeded)
+}
+
+func TestKeyedTasksDontBlock(t *testing.T) {
+	// Create new bounded runner, and add more tasks with the same key than the capacity before running it
+	const count = 5
+	runner := NewBounedRunner(2, false)
+	var executed atomic.Int32
+	for i := 0; i < count; i++ {
+		taskId, err := runner.AddKeyedTask("path", func(int) error {
+			executed.Add(1)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, i, taskId)
+	}
+
+	// Done is invoked before the held tasks are queued
+	runner.Done()
+	runner.Run()
+	assert.Equal(t, int32(count), executed.Load())
+}
+
+func TestTimedOutTaskHoldsKeyAndWeight(t *testing.T) {
+	for name, option := range map[string]TaskOption{"key": WithKey("path"), "weight": WithWeight(10)} {
+		t.Run(name, func(t *testing.T) {
+			// Create new runner, and add a task ignoring its timeout, followed by a task sharing its key or weight
+			runner := NewRunner(2, 2, false)
+			runner.SetMaxWeight(10)
+			var returned atomic.Bool
+			_, err := runner.AddTaskWithOptions(func(context.Context, int) error {
+				time.Sleep(100 * time.Millisecond)
+				returned.Store(true)
+				return nil
+			}, option, WithTimeout(10*time.Millisecond))
+			assert.NoError(t, err)
+			_, err = runner.AddTaskWithOptions(func(context.Context, int) error {
+				// Expect the task to start only after the timed out task returned
+				assert.True(t, returned.Load())
+				return nil
+			}, option)
+			assert.NoError(t, err)
+			runner.Done()
+			runner.Run()
+			assert.Equal(t, uint32(1), runner.Stats().Succeeded)
+			assert.Equal(t, uint32(1), runner.Stats().Failed)
+		})
+	}
+}
+
+func TestCancelKeyedTasks(t *testing.T) {
+	// Create new runner with a running keyed task, and tasks waiting for it
+	runner := NewRunner(2, 5, false)
+	started := make(chan bool)
+	_, err := runner.AddKeyedTask("path", func(int) error {
+		close(started)
+		<-runner.ctx.Done()
+		return nil
+	})
+	assert.NoError(t, err)
+	var executed atomic.Int32
+	for i := 0; i < 3; i++ {
+		_, err = runner.AddKeyedTask("path", func(int) error {
+			executed.Add(1)
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+
+	// Cancelling the runner drops the waiting tasks
+	go func() {
+		<-started
+		runner.Cancel(false)
+	}()
+	runner.Run()
+	assert.Zero(t, executed.Load())
+	assert.Eventually(t, func() bool { return runner.Stats().Cancelled == 3 }, time.Second, time.Millisecond)
+	assert.Zero(t, runner.Stats().Queued)
+}
+
+func TestDrainKeyedTasks(t *testing.T) {
+	// Create new runner with a running keyed task, and keyed tasks waiting for it or for a queued task
+	runner := NewRunner(1, 5, false)
+	started := make(chan bool)
+	finish := make(chan bool)
+	_, err := runner.AddKeyedTask("running", func(int) error {
+		close(started)
+		<-finish
+		return nil
+	})
+	assert.NoError(t, err)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runner.Run()
+	}()
+	<-started
+	runner.Pause()
+	var executed atomic.Int32
+	for _, key := range []string{"running", "queued", "running", "queued"} {
+		_, err = runner.AddKeyedTask(key, func(int) error {
+			executed.Add(1)
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+
+	// A single drain returns all the waiting tasks, ordered by their task number, without queueing their next tasks
+	pendingTasks := runner.Drain()
+	assert.Len(t, pendingTasks, 4)
+	for i, pendingTask := range pendingTasks {
+		assert.Equal(t, i+1, pendingTask.TaskId)
+	}
+	assert.Equal(t, "queued", pendingTasks[1].Key)
+	assert.Empty(t, runner.Drain())
+	assert.Zero(t, runner.Stats().Queued)
+
+	// The drained tasks don't run after resuming
+	runner.Resume()
+	close(finish)
+	runner.Done()
+	wg.Wait()
+	assert.Zero(t, executed.Load())
+	assert.Equal(t, uint32(1), runner.Stats().Succeeded)
+}
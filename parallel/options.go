@@ -0,0 +1,66 @@
+package parallel
+
+import (
+	"time"
+)
+
+// TaskOption configures a task added with AddTaskWithOptions. Options can be combined, for example a retried task with
+// a key and a label.
+type TaskOption func(t *task)
+
+// WithErrorHandler executes the handler on the error returned from the task.
+func WithErrorHandler(errorHandler OnErrorFunc) TaskOption {
+	return func(t *task) {
+		t.onError = errorHandler
+	}
+}
+
+// WithPriority places the task in the lane of the given priority (see @AddTaskWithPriority()).
+func WithPriority(priority int) TaskOption {
+	return func(t *task) {
+		t.priority = priority
+	}
+}
+
+// WithRetry re-enqueues the task when it fails, according to the retry policy (see @AddTaskWithRetry()).
+func WithRetry(policy RetryPolicy) TaskOption {
+	return func(t *task) {
+		t.retryPolicy = &policy
+	}
+}
+
+// WithWeight sets the cost of the task, such as the size of the file the task transfers (see @AddTaskWithWeight()).
+func WithWeight(weight int64) TaskOption {
+	return func(t *task) {
+		t.weight = weight
+	}
+}
+
+// WithTimeout cancels each attempt of the task that doesn't finish within the timeout (see @AddContextTaskWithTimeout()).
+func WithTimeout(timeout time.Duration) TaskOption {
+	return func(t *task) {
+		t.timeout = timeout
+	}
+}
+
+// WithDescriptor records the task in the runner's journal (see @AddDescribedTask()).
+func WithDescriptor(descriptor TaskDescriptor) TaskOption {
+	return func(t *task) {
+		t.descriptor = &descriptor
+	}
+}
+
+// WithKey runs the task after the previous tasks with the same key, and not concurrently with them (see @AddKeyedTask()).
+func WithKey(key string) TaskOption {
+	return func(t *task) {
+		t.key = key
+	}
+}
+
+// WithLabel sets a description of the task, such as the path of the file the task transfers. The label is reported if
+// the task fails (see @Err()).
+func WithLabel(label string) TaskOption {
+	return func(t *task) {
+		t.label = label
+	}
+}
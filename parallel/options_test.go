@@ -0,0 +1,44 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddTaskWithOptions(t *testing.T) {
+	// Create new runner, and add a retried, keyed, weighted and timed out task with a label
+	runner := NewRunner(2, 5, false)
+	runner.SetMaxWeight(10)
+	var handledErr error
+	taskId, err := runner.AddTaskWithOptions(func(ctx context.Context, _ int) error {
+		<-ctx.Done()
+		return ctx.Err()
+	},
+		WithRetry(RetryPolicy{MaxAttempts: 2}),
+		WithKey("path"),
+		WithWeight(5),
+		WithTimeout(10*time.Millisecond),
+		WithLabel("upload a.zip"),
+		WithErrorHandler(func(err error) { handledErr = err }),
+	)
+	assert.NoError(t, err)
+	runner.Done()
+	runner.Run()
+
+	// Expect all the options to apply to the task
+	var runnerErr *RunnerError
+	require.True(t, errors.As(runner.Err(), &runnerErr))
+	require.Len(t, runnerErr.Failures, 1)
+	failure := runnerErr.Failures[0]
+	assert.Equal(t, taskId, failure.TaskId)
+	assert.Equal(t, "upload a.zip", failure.Label)
+	assert.Equal(t, 2, failure.Attempt)
+	assert.IsType(t, TaskTimeoutError{}, failure.Err)
+	assert.Equal(t, failure.Err, handledErr)
+	assert.Equal(t, map[int]int{taskId: 2}, runner.Attempts())
+}
@@ -0,0 +1,133 @@
+package parallel
+
+import (
+	"sort"
+)
+
+// PendingTask is a task that was removed from the runner before it started (see @Drain()).
+type PendingTask struct {
+	// The task number, as returned when the task was added.
+	TaskId int
+	// The priority of the task.
+	Priority int
+	// The task itself. Tasks added without a context ignore the context passed to them.
+	Task ContextTaskFunc
+	// The descriptor of a task added with AddDescribedTask, nil for other tasks.
+	Descriptor *TaskDescriptor
+	// The key of a task added with AddKeyedTask, empty for other tasks.
+	Key string
+}
+
+// Pause stops the threads from taking new tasks from the queue.
+// Tasks that already started continue running. Tasks can still be added while the runner is paused.
+// If this Runner is already paused, then this function will do nothing.
+func (r *runner) Pause() {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	if r.paused.Load() {
+		return
+	}
+	r.paused.Store(true)
+	r.resumed = make(chan struct{})
+}
+
+// Resume lets the threads take new tasks from the queue, after the runner was paused.
+// If this Runner isn't paused, then this function will do nothing.
+func (r *runner) Resume() {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	if !r.paused.Load() {
+		return
+	}
+	r.paused.Store(false)
+	close(r.resumed)
+}
+
+// IsPaused is true when the runner is paused, false otherwise.
+func (r *runner) IsPaused() bool {
+	return r.paused.Load()
+}
+
+// Drain removes the tasks that didn't start from the queue, and returns them ordered by their task number.
+// Typically called after Pause, so the returned tasks can be persisted and added to a runner later.
+// Failed tasks waiting for a retry are not returned. Keyed tasks waiting for a previous task with their key are
+// returned, so re-adding the returned tasks in order keeps the order of the tasks with the same key.
+func (r *runner) Drain() []PendingTask {
+	tasks := r.takePendingTasks()
+	tasks = append(tasks, r.takeWaitingKeyedTasks(tasks)...)
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].num < tasks[j].num
+	})
+	pendingTasks := make([]PendingTask, 0, len(tasks))
+	for _, t := range tasks {
+		// The keys were already released, without queueing the next tasks with the same keys.
+		r.totalTasksInQueue.Add(^uint32(0))
+		r.finishDeferredTask(t)
+		if t.onDrain != nil {
+			t.onDrain()
+		}
+		pendingTasks = append(pendingTasks, PendingTask{TaskId: int(t.num), Priority: t.priority, Task: t.run, Descriptor: t.descriptor, Key: t.key})
+	}
+	return pendingTasks
+}
+
+// Blocks while the runner is paused. Returns false if the runner was cancelled.
+func (r *runner) waitWhilePaused() bool {
+	// Avoid the lock when the runner isn't paused.
+	if !r.paused.Load() {
+		return r.ctx.Err() == nil
+	}
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	for r.paused.Load() && r.ctx.Err() == nil {
+		resumed := r.resumed
+		r.pauseLock.Unlock()
+		select {
+		case <-resumed:
+		case <-r.ctx.Done():
+		}
+		r.pauseLock.Lock()
+	}
+	return r.ctx.Err() == nil
+}
+
+// Called after a task was taken from the queue. If the runner was paused meanwhile, the task is held until the runner
+// is resumed, so it can be drained.
+// Returns false if the task was drained or the runner was cancelled while the task was held.
+func (r *runner) holdWhilePaused(t *task) bool {
+	if !r.paused.Load() {
+		return true
+	}
+	r.pauseLock.Lock()
+	if !r.paused.Load() {
+		r.pauseLock.Unlock()
+		return true
+	}
+	r.heldTasks = append(r.heldTasks, t)
+	r.pauseLock.Unlock()
+
+	cancelled := !r.waitWhilePaused()
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	for i, heldTask := range r.heldTasks {
+		if heldTask == t {
+			r.heldTasks = append(r.heldTasks[:i], r.heldTasks[i+1:]...)
+			if cancelled {
+				r.dropTask(t)
+				return false
+			}
+			return true
+		}
+	}
+	// The task was drained.
+	return false
+}
+
+// Take the held tasks and the tasks left in the queue.
+func (r *runner) takePendingTasks() []*task {
+	r.pauseLock.Lock()
+	tasks := r.heldTasks
+	r.heldTasks = nil
+	r.pauseLock.Unlock()
+	return append(tasks, r.tasks.drain()...)
+}
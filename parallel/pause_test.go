@@ -0,0 +1,149 @@
+package parallel
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPauseAndResume(t *testing.T) {
+	// Create new runner
+	const count = 6
+	runner := NewRunner(2, count, false)
+	var executed atomic.Int32
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runner.Run()
+	}()
+
+	// Pause, and add tasks while paused
+	runner.Pause()
+	assert.True(t, runner.IsPaused())
+	for i := 0; i < count; i++ {
+		_, err := runner.AddTask(func(int) error {
+			executed.Add(1)
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Zero(t, executed.Load())
+
+	// Resume, and wait for the tasks to finish
+	runner.Resume()
+	assert.False(t, runner.IsPaused())
+	runner.Done()
+	wg.Wait()
+	assert.Equal(t, int32(count), executed.Load())
+}
+
+func TestPauseLetsRunningTasksFinish(t *testing.T) {
+	// Create new runner with a running task
+	runner := NewRunner(1, 2, false)
+	started := make(chan bool)
+	finish := make(chan bool)
+	var finished atomic.Bool
+	_, err := runner.AddTask(func(int) error {
+		close(started)
+		<-finish
+		finished.Store(true)
+		return nil
+	})
+	assert.NoError(t, err)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runner.Run()
+	}()
+
+	// Pause while the task is running
+	<-started
+	runner.Pause()
+	close(finish)
+	assert.Eventually(t, finished.Load, time.Second, time.Millisecond)
+
+	// Expect the paused runner to not start the next task
+	secondStarted := false
+	_, err = runner.AddTask(func(int) error {
+		secondStarted = true
+		return nil
+	})
+	assert.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, secondStarted)
+	assert.Len(t, runner.Drain(), 1)
+
+	runner.Done()
+	runner.Resume()
+	wg.Wait()
+	assert.False(t, secondStarted)
+}
+
+func TestDrain(t *testing.T) {
+	// Create new runner, and wait for its threads to wait for tasks
+	const count = 5
+	runner := NewPriorityRunner(3, count, 2, false)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runner.Run()
+	}()
+	assert.Eventually(t, func() bool { return runner.OpenThreads() == 3 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+
+	// Threads waiting for tasks take tasks added after the runner was paused, and must hold them
+	runner.Pause()
+	var executed atomic.Int32
+	for i := 0; i < count; i++ {
+		_, err := runner.AddTaskWithPriority(func(int) error {
+			executed.Add(1)
+			return nil
+		}, i%2)
+		assert.NoError(t, err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// Expect all the tasks to be drained, ordered by their task number
+	pendingTasks := runner.Drain()
+	assert.Len(t, pendingTasks, count)
+	for i, pendingTask := range pendingTasks {
+		assert.Equal(t, i, pendingTask.TaskId)
+		assert.Equal(t, i%2, pendingTask.Priority)
+	}
+	assert.Empty(t, runner.Drain())
+	assert.Zero(t, runner.Stats().Queued)
+
+	// Re-add a drained task after resuming
+	runner.Resume()
+	_, err := runner.AddContextTask(pendingTasks[0].Task)
+	assert.NoError(t, err)
+	runner.Done()
+	wg.Wait()
+	assert.Equal(t, int32(1), executed.Load())
+}
+
+func TestCancelPausedRunner(t *testing.T) {
+	// Create new paused runner
+	runner := NewRunner(2, 2, false)
+	runner.Pause()
+	for i := 0; i < 2; i++ {
+		_, err := runner.AddTask(func(int) error { return nil })
+		assert.NoError(t, err)
+	}
+
+	// Cancelling stops the paused threads
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		runner.Cancel(false)
+	}()
+	runner.Run()
+	assert.Zero(t, runner.started)
+	assert.Equal(t, uint32(2), runner.Stats().Cancelled)
+}
@@ -0,0 +1,177 @@
+package parallel
+
+import (
+	"context"
+	"sync"
+)
+
+// taskQueue holds the tasks waiting to be executed by the runner's threads.
+type taskQueue interface {
+	// Add a task to the queue. Blocks while the queue is full.
+	// Returns false if the queue's context is done or the queue is closed before the task was added.
+	push(t *task) bool
+	// Take the next task from the queue. Blocks while the queue is empty.
+	// Returns false if the queue is closed and empty, or if the queue's context is done.
+	pop() (*task, bool)
+	// Take all the tasks left in the queue, without blocking.
+	drain() []*task
+	// Notify that no more tasks will be added to the queue.
+	close()
+}
+
+// A FIFO queue backed by a buffered channel.
+type channelQueue struct {
+	ctx   context.Context
+	tasks chan *task
+}
+
+func newChannelQueue(ctx context.Context, capacity uint) *channelQueue {
+	return &channelQueue{ctx: ctx, tasks: make(chan *task, capacity)}
+}
+
+func (q *channelQueue) push(t *task) bool {
+	// Checked first, since select chooses randomly when the channel also has a free slot.
+	if q.ctx.Err() != nil {
+		return false
+	}
+	select {
+	case q.tasks <- t:
+		return true
+	case <-q.ctx.Done():
+		return false
+	}
+}
+
+func (q *channelQueue) pop() (*task, bool) {
+	select {
+	case <-q.ctx.Done():
+		return nil, false
+	case t, ok := <-q.tasks:
+		return t, ok
+	}
+}
+
+func (q *channelQueue) drain() (tasks []*task) {
+	for {
+		select {
+		case t, ok := <-q.tasks:
+			if !ok {
+				return
+			}
+			tasks = append(tasks, t)
+		default:
+			return
+		}
+	}
+}
+
+func (q *channelQueue) close() {
+	close(q.tasks)
+}
+
+// A queue with a FIFO lane per priority. Tasks are always taken from the highest non-empty lane.
+type priorityQueue struct {
+	ctx context.Context
+	// Tasks waiting to be executed, lanes[i] holds the tasks with priority i.
+	lanes [][]*task
+	// The total number of tasks in all the lanes.
+	size int
+	// The maximum number of tasks in all the lanes.
+	capacity int
+	// True when no more tasks will be added.
+	closed bool
+	// A lock on the lanes.
+	lock sync.Mutex
+	// Signaled when a task is added, the queue is closed or the context is done.
+	notEmpty *sync.Cond
+	// Signaled when a task is taken, the queue is closed or the context is done.
+	notFull *sync.Cond
+}
+
+func newPriorityQueue(ctx context.Context, capacity uint, priorities int) *priorityQueue {
+	if priorities < 1 {
+		priorities = 1
+	}
+	q := &priorityQueue{ctx: ctx, lanes: make([][]*task, priorities), capacity: int(capacity)}
+	q.notEmpty = sync.NewCond(&q.lock)
+	q.notFull = sync.NewCond(&q.lock)
+	// Wake up the waiting threads when the context is done.
+	context.AfterFunc(ctx, q.wakeAll)
+	return q
+}
+
+func (q *priorityQueue) push(t *task) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for q.size >= q.capacity && !q.closed && q.ctx.Err() == nil {
+		q.notFull.Wait()
+	}
+	if q.closed || q.ctx.Err() != nil {
+		return false
+	}
+	lane := q.laneOf(t.priority)
+	q.lanes[lane] = append(q.lanes[lane], t)
+	q.size++
+	q.notEmpty.Signal()
+	return true
+}
+
+func (q *priorityQueue) pop() (*task, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for q.size == 0 && !q.closed && q.ctx.Err() == nil {
+		q.notEmpty.Wait()
+	}
+	if q.size == 0 || q.ctx.Err() != nil {
+		return nil, false
+	}
+	for lane := len(q.lanes) - 1; lane >= 0; lane-- {
+		if len(q.lanes[lane]) > 0 {
+			t := q.lanes[lane][0]
+			q.lanes[lane][0] = nil
+			q.lanes[lane] = q.lanes[lane][1:]
+			q.size--
+			q.notFull.Signal()
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+func (q *priorityQueue) drain() (tasks []*task) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for lane := len(q.lanes) - 1; lane >= 0; lane-- {
+		tasks = append(tasks, q.lanes[lane]...)
+		q.lanes[lane] = nil
+	}
+	q.size = 0
+	q.notFull.Broadcast()
+	return
+}
+
+func (q *priorityQueue) close() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.closed = true
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+}
+
+func (q *priorityQueue) wakeAll() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+}
+
+// Returns the lane of the given priority. Priorities out of range are assigned to the nearest lane.
+func (q *priorityQueue) laneOf(priority int) int {
+	if priority < 0 {
+		return 0
+	}
+	if priority >= len(q.lanes) {
+		return len(q.lanes) - 1
+	}
+	return priority
+}
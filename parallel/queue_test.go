@@ -0,0 +1,44 @@
+package parallel
+
+import (
+	"crypto/sha256"
+	"sync/atomic"
+	"testing"
+)
+
+func BenchmarkChannelRunnerSmallTasks(b *testing.B) {
+	benchmarkRunner(b, NewRunner(8, 1000, false), smallBenchmarkTask)
+}
+
+func BenchmarkChannelRunnerLargeTasks(b *testing.B) {
+	benchmarkRunner(b, NewRunner(8, 1000, false), largeBenchmarkTask)
+}
+
+var benchmarkCounter atomic.Int64
+
+func smallBenchmarkTask(int) error {
+	benchmarkCounter.Add(1)
+	return nil
+}
+
+var benchmarkData = make([]byte, 16*1024)
+
+func largeBenchmarkTask(int) error {
+	sha256.Sum256(benchmarkData)
+	return nil
+}
+
+func benchmarkRunner(b *testing.B, runner *runner, task TaskFunc) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	go func() {
+		defer runner.Done()
+		for i := 0; i < b.N; i++ {
+			if _, err := runner.AddTask(task); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	}()
+	runner.Run()
+}
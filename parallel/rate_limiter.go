@@ -0,0 +1,62 @@
+package parallel
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A token bucket rate limiter. The bucket is refilled at a constant rate, and each task consumes a single token.
+type rateLimiter struct {
+	// The number of tokens added to the bucket per second.
+	rate float64
+	// The maximum number of tokens in the bucket.
+	burst float64
+	// The number of tokens in the bucket. Negative when tasks are waiting for tokens that weren't added yet.
+	tokens float64
+	// The last time the tokens were updated.
+	lastUpdate time.Time
+	// A lock on the tokens.
+	lock sync.Mutex
+}
+
+func newRateLimiter(tasksPerSecond float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: tasksPerSecond, burst: float64(burst), tokens: float64(burst), lastUpdate: time.Now()}
+}
+
+// Blocks until a token is available. Returns the context's error if the context is done before.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	delay := l.reserve()
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Return the reserved token.
+		l.lock.Lock()
+		l.tokens++
+		l.lock.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Take a token from the bucket, and return the time to wait until the token is added.
+func (l *rateLimiter) reserve() time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.lastUpdate).Seconds()*l.rate)
+	l.lastUpdate = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
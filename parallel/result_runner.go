@@ -0,0 +1,114 @@
+package parallel
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// ResultTaskFunc is a task that returns a value.
+type ResultTaskFunc[T any] func(threadId int) (T, error)
+
+// TaskResult is the outcome of a task added to a ResultRunner.
+type TaskResult[T any] struct {
+	// The task number, as returned from AddResultTask.
+	TaskId int
+	// The value returned from the task, even if the task failed. The zero value if the task panicked or timed out.
+	Value T
+	// The error returned from the task.
+	Err error
+}
+
+// ResultRunner is a runner of tasks that return values.
+// The results are collected, and can be retrieved in the order the tasks were added after Run returns, or streamed
+// while the tasks are finishing.
+type ResultRunner[T any] struct {
+	*runner
+	// The results of the finished tasks, keyed by the task number.
+	results map[int]TaskResult[T]
+	// A lock on the results map.
+	resultsLock sync.Mutex
+	// A channel receiving each result when its task finishes. Closed when Run returns.
+	resultsStream chan TaskResult[T]
+	// A flag that allows receiving the results through the results stream.
+	resultsStreamEnabled bool
+}
+
+// Create a new result runner.
+// maxParallel - number of go routines for task processing, maxParallel always will be a positive number.
+// capacity - number of tasks that can be added until a free processing goroutine is needed.
+// failFast - is set to true the will stop on first error.
+func NewResultRunner[T any](maxParallel int, capacity uint, failFast bool) *ResultRunner[T] {
+	return &ResultRunner[T]{
+		runner:        NewRunner(maxParallel, capacity, failFast),
+		results:       make(map[int]TaskResult[T]),
+		resultsStream: make(chan TaskResult[T], max(capacity, 1)),
+	}
+}
+
+// Add a task that returns a value, in case of cancellation event (caused by @Cancel()) will return non nil error.
+// Return the task number assigned to t. Useful to find the result of t (see @Results()).
+// The result is reported once the task finishes, with the error the runner records for it, including the
+// TaskPanicError of a task that panicked and the TaskTimeoutError of a task that timed out.
+func (rr *ResultRunner[T]) AddResultTask(t ResultTaskFunc[T]) (int, error) {
+	var value T
+	resultTask := &task{}
+	resultTask.run = func(_ context.Context, threadId int) (err error) {
+		value, err = t(threadId)
+		return err
+	}
+	resultTask.onFinish = func(err error) {
+		result := TaskResult[T]{TaskId: int(resultTask.num), Err: err}
+		if _, timedOut := err.(TaskTimeoutError); !timedOut {
+			// A task that timed out may still be running, so its value is not reported.
+			result.Value = value
+		}
+		rr.addResult(result)
+	}
+	return rr.runner.addTask(resultTask)
+}
+
+// Run the tasks. When the results stream is enabled, the stream is closed once all the tasks are done.
+// Notice: Run() is a blocking operation.
+func (rr *ResultRunner[T]) Run() {
+	rr.runner.Run()
+	if rr.resultsStreamEnabled {
+		close(rr.resultsStream)
+	}
+}
+
+// Results returns the results of the finished tasks, in the order the tasks were added.
+func (rr *ResultRunner[T]) Results() []TaskResult[T] {
+	rr.resultsLock.Lock()
+	defer rr.resultsLock.Unlock()
+	results := make([]TaskResult[T], 0, len(rr.results))
+	for _, result := range rr.results {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].TaskId < results[j].TaskId
+	})
+	return results
+}
+
+// SetResultsStream enables receiving the results through the channel returned from GetResultsStream.
+// Must be called before Run. When enabled, the stream must be consumed while the runner is running, since the tasks
+// block until their results are received.
+func (rr *ResultRunner[T]) SetResultsStream(toEnable bool) {
+	rr.resultsStreamEnabled = toEnable
+}
+
+// GetResultsStream returns a channel receiving the results in the order the tasks finish.
+// In order to use the results stream, you must call SetResultsStream(true).
+func (rr *ResultRunner[T]) GetResultsStream() <-chan TaskResult[T] {
+	return rr.resultsStream
+}
+
+func (rr *ResultRunner[T]) addResult(result TaskResult[T]) {
+	rr.resultsLock.Lock()
+	rr.results[result.TaskId] = result
+	rr.resultsLock.Unlock()
+	if rr.resultsStreamEnabled {
+		rr.resultsStream <- result
+	}
+}
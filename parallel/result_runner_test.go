@@ -0,0 +1,148 @@
+package parallel
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultRunnerResults(t *testing.T) {
+	// Create new result runner
+	const count = 20
+	runner := NewResultRunner[string](4, count, false)
+
+	// Add tasks that finish in reverse order
+	for i := 0; i < count; i++ {
+		value := i
+		_, err := runner.AddResultTask(func(int) (string, error) {
+			time.Sleep(time.Duration(count-value) * time.Millisecond)
+			if value%5 == 0 {
+				return "", fmt.Errorf("task %d failed", value)
+			}
+			return fmt.Sprintf("value %d", value), nil
+		})
+		assert.NoError(t, err)
+	}
+	runner.Done()
+	runner.Run()
+
+	// Expect the results in the order the tasks were added
+	results := runner.Results()
+	assert.Len(t, results, count)
+	for i, result := range results {
+		assert.Equal(t, i, result.TaskId)
+		if i%5 == 0 {
+			assert.EqualError(t, result.Err, fmt.Sprintf("task %d failed", i))
+			assert.Empty(t, result.Value)
+			assert.Equal(t, result.Err, runner.Errors()[i])
+		} else {
+			assert.NoError(t, result.Err)
+			assert.Equal(t, fmt.Sprintf("value %d", i), result.Value)
+		}
+	}
+}
+
+func TestResultRunnerPanic(t *testing.T) {
+	// Create new result runner, and add a task that panics
+	runner := NewResultRunner[string](1, 1, false)
+	taskId, err := runner.AddResultTask(func(int) (string, error) {
+		panic("result task panicked")
+	})
+	assert.NoError(t, err)
+	runner.Done()
+	runner.Run()
+
+	// Expect the result of the task to be reported with the panic
+	results := runner.Results()
+	assert.Len(t, results, 1)
+	var panicErr TaskPanicError
+	if assert.ErrorAs(t, results[0].Err, &panicErr) {
+		assert.Equal(t, "result task panicked", panicErr.Value)
+		assert.NotEmpty(t, panicErr.Stack)
+	}
+	assert.Empty(t, results[0].Value)
+	assert.Equal(t, runner.Errors()[taskId], results[0].Err)
+}
+
+func TestResultRunnerTimeout(t *testing.T) {
+	// Create new result runner with a results stream, and add a task that runs longer than the default timeout
+	runner := NewResultRunner[string](1, 1, false)
+	runner.SetDefaultTaskTimeout(20 * time.Millisecond)
+	runner.SetResultsStream(true)
+	returned := make(chan struct{})
+	taskId, err := runner.AddResultTask(func(int) (string, error) {
+		defer close(returned)
+		time.Sleep(200 * time.Millisecond)
+		return "late value", nil
+	})
+	assert.NoError(t, err)
+	runner.Done()
+	go runner.Run()
+
+	// Expect the timeout to be streamed before the stream is closed
+	var streamed []TaskResult[string]
+	for result := range runner.GetResultsStream() {
+		streamed = append(streamed, result)
+	}
+	assert.Len(t, streamed, 1)
+	assert.Equal(t, runner.Results(), streamed)
+	assert.IsType(t, TaskTimeoutError{}, streamed[0].Err)
+	assert.Empty(t, streamed[0].Value)
+	assert.Equal(t, runner.Errors()[taskId], streamed[0].Err)
+
+	// Expect the result to stay unchanged once the task returns
+	<-returned
+	assert.Equal(t, streamed, runner.Results())
+}
+
+func TestResultRunnerStream(t *testing.T) {
+	// Create new result runner with a results stream
+	const count = 10
+	runner := NewResultRunner[int](3, 1, false)
+	runner.SetResultsStream(true)
+
+	// Produce
+	go func() {
+		defer runner.Done()
+		for i := 0; i < count; i++ {
+			value := i
+			_, err := runner.AddResultTask(func(int) (int, error) {
+				return value * value, nil
+			})
+			assert.NoError(t, err)
+		}
+	}()
+	go runner.Run()
+
+	// Consume the stream until it is closed
+	streamed := make(map[int]int)
+	for result := range runner.GetResultsStream() {
+		assert.NoError(t, result.Err)
+		streamed[result.TaskId] = result.Value
+	}
+	assert.Len(t, streamed, count)
+	for taskId, value := range streamed {
+		assert.Equal(t, taskId*taskId, value)
+	}
+}
+
+func TestResultRunnerFailFast(t *testing.T) {
+	// Create new result runner with fail-fast
+	runner := NewResultRunner[int](1, 1, true)
+
+	// Add a failing task
+	_, err := runner.AddResultTask(func(int) (int, error) {
+		return 0, errTest
+	})
+	assert.NoError(t, err)
+	runner.Run()
+	assert.Equal(t, []TaskResult[int]{{TaskId: 0, Err: errTest}}, runner.Results())
+
+	// Add another task and expect error
+	_, err = runner.AddResultTask(func(int) (int, error) {
+		return 1, nil
+	})
+	assert.ErrorContains(t, err, "runner stopped")
+}
@@ -0,0 +1,45 @@
+package parallel
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jfrog/gofrog/http/retryexecutor"
+)
+
+// RetryPolicy defines how the runner retries a task that returned an error.
+// Failed attempts are re-enqueued, so other tasks keep running while the task waits for its next attempt.
+type RetryPolicy struct {
+	// The maximum number of times the task is executed, including the first attempt.
+	MaxAttempts int
+	// The strategy of the intervals before re-enqueuing the task, such as retryexecutor.ExponentialBackoff.
+	// The strategy receives the number of the failed attempt, starting from 0. If nil, the task is re-enqueued immediately.
+	// A task may return a retryexecutor.RetryAfterError to override the interval before its next attempt.
+	Backoff retryexecutor.BackoffStrategy
+	// Decides whether the error returned from the task should be retried. If nil, all errors are retried.
+	IsRetryable retryexecutor.Classifier
+}
+
+// attempt - the number of times the task was executed, starting from 1.
+func (p *RetryPolicy) shouldRetry(attempt int, err error) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+	return p.IsRetryable == nil || p.IsRetryable(err)
+}
+
+// Returns the interval before re-enqueuing the task. The delay of a retryexecutor.RetryAfterError returned from the task
+// overrides the backoff strategy, as in retryexecutor.
+// attempt - the number of times the task was executed, starting from 1.
+// previous - the interval before the failed attempt, or zero after the first attempt.
+// err - the error returned in the failed attempt.
+func (p *RetryPolicy) backoff(attempt int, previous time.Duration, err error) time.Duration {
+	var retryAfterErr retryexecutor.RetryAfterError
+	if errors.As(err, &retryAfterErr) && retryAfterErr.Delay > 0 {
+		return retryAfterErr.Delay
+	}
+	if p.Backoff == nil {
+		return 0
+	}
+	return p.Backoff(attempt-1, previous)
+}
@@ -1,10 +1,17 @@
 package parallel
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/jfrog/gofrog/log"
+	"golang.org/x/sync/semaphore"
 )
 
 const waitForTasksTime = 10 * time.Second
@@ -27,38 +34,84 @@ type Runner interface {
 
 type TaskFunc func(int) error
 
+// ContextTaskFunc is a task that receives the runner's context.
+// The context is cancelled when the runner is cancelled, either by calling Cancel, by a fail-fast error or by
+// cancelling the parent context the runner was created with.
+type ContextTaskFunc func(ctx context.Context, threadId int) error
+
 type OnErrorFunc func(error)
 
 type task struct {
-	run     TaskFunc
+	run     ContextTaskFunc
 	onError OnErrorFunc
 	num     uint32
+	// The priority lane of the task, used by priority runners.
+	priority int
+	// If not nil, the task is re-enqueued when it fails, according to the policy.
+	retryPolicy *RetryPolicy
+	// The number of times the task was executed.
+	attempt int
+	// The interval the task waited before its last retry.
+	retryInterval time.Duration
+	// The cost of the task, used to limit the total weight of the running tasks.
+	weight int64
+	// The maximum duration of each attempt of the task. If zero, the runner's default timeout is used.
+	timeout time.Duration
+	// If not nil, the task is recorded in the runner's journal.
+	descriptor *TaskDescriptor
+	// True if the task was resumed from the journal, so it is already recorded as added.
+	resumed bool
+	// If not empty, the task doesn't run concurrently with other tasks with the same key, and runs after them.
+	key string
+	// A description of the task, reported when the task fails (see @Err()).
+	label string
+	// If not nil, invoked when the task is not executed, since the runner was cancelled.
+	onDrop func()
+	// If not nil, invoked when the task is removed from the runner by Drain.
+	onDrain func()
+	// If not nil, invoked with the final error of the task, or nil if it succeeded, once it will not be retried.
+	onFinish func(error)
 }
 
 type runner struct {
+	// The context passed to the tasks. Cancelled when the runner is cancelled.
+	ctx context.Context
+	// Cancels ctx.
+	cancelFunc context.CancelFunc
+	// Stops cancelling the runner when the parent context is done.
+	stopParentCancel func() bool
 	// Tasks waiting to be executed.
-	tasks chan *task
+	tasks taskQueue
 	// Tasks counter, used to give each task an identifier (task.num).
 	taskId uint32
 	// True when Cancel was invoked
 	cancel atomic.Bool
 	// Used to make sure that cancel is called only once.
 	cancelOnce sync.Once
-	// Used to make sure that done is called only once.
+	// Used to make sure that the tasks queue is closed only once.
 	doneOnce sync.Once
+	// True when Done was invoked.
+	done bool
+	// The number of tasks with a retry policy or a key that didn't finish yet. Since these tasks may be pushed to the
+	// queue after Done is invoked, the tasks queue is closed only when there are none.
+	unfinishedDeferredTasks int
+	// A lock on done and unfinishedDeferredTasks.
+	deferredTasksLock sync.Mutex
 	// The maximum number of threads running in parallel.
-	maxParallel int
+	maxParallel atomic.Int32
 	// If true, the runner will be cancelled on the first error thrown from a task.
 	failFast bool
 	// Indicates that the runner received some tasks and started executing them.
 	started uint32
 	// A WaitGroup that waits for all the threads to close.
 	threadsWaitGroup sync.WaitGroup
+	// A WaitGroup that waits for the failed tasks waiting for a retry to be re-enqueued or dropped.
+	retriesWaitGroup sync.WaitGroup
 	// Threads counter, used to give each thread an identifier (threadId).
 	threadCount atomic.Uint32
 	// The number of open threads.
 	openThreads atomic.Uint32
-	// A lock on openThreads.
+	// A lock on openThreads and on changing maxParallel.
 	openThreadsLock sync.Mutex
 	// The number of threads currently running tasks.
 	activeThreads atomic.Uint32
@@ -72,10 +125,60 @@ type runner struct {
 	finishedNotifierLock sync.Mutex
 	// A flag that allows receiving a notification through a channel, when the runner finishes executing all the tasks.
 	finishedNotificationEnabled bool
-	// A map of errors keyed by threadId.
-	errors map[int]error
-	// A lock on the errors map.
+	// A map of the failures of the tasks, keyed by the task number.
+	failures map[int]TaskFailure
+	// A lock on the failures map.
 	errorsLock sync.Mutex
+	// A map of the number of attempts of tasks added with a retry policy, keyed by the task number.
+	attempts map[int]int
+	// A lock on the attempts map.
+	attemptsLock sync.Mutex
+	// If not nil, limits the number of tasks started per second.
+	rateLimiter *rateLimiter
+	// If not nil, limits the total weight of the running tasks.
+	weights *semaphore.Weighted
+	// The maximum total weight of the running tasks.
+	maxWeight int64
+	// The number of tasks that finished successfully.
+	succeededTasks atomic.Uint32
+	// The number of tasks that failed, after all their attempts.
+	failedTasks atomic.Uint32
+	// The number of tasks that were not executed, since the runner was cancelled.
+	cancelledTasks atomic.Uint32
+	// The durations of the attempts of the tasks.
+	durations durationRecorder
+	// The start time of the first task, in nanoseconds since the epoch.
+	firstTaskStart atomic.Int64
+	// The end time of the last finished task, in nanoseconds since the epoch.
+	lastTaskEnd atomic.Int64
+	// Hooks invoked when a task starts running.
+	taskStartHooks []TaskStartHook
+	// Hooks invoked after each attempt of a task.
+	taskDoneHooks []TaskDoneHook
+	// Hooks invoked when the runner becomes idle.
+	idleHooks []IdleHook
+	// The maximum duration of each attempt of a task without a timeout. If zero, such tasks have no timeout.
+	defaultTaskTimeout time.Duration
+	// The start time of the running tasks, keyed by the task number. Recorded only if the watchdog is enabled.
+	runningTasks map[int]time.Time
+	// A lock on runningTasks.
+	runningTasksLock sync.Mutex
+	// If positive, a warning is logged for tasks running longer than this threshold.
+	watchdogThreshold time.Duration
+	// True while the runner is paused.
+	paused atomic.Bool
+	// Closed when the runner is resumed.
+	resumed chan struct{}
+	// Tasks taken from the queue while the runner was paused, waiting for the runner to be resumed.
+	heldTasks []*task
+	// A lock on pausing and resuming, and on heldTasks.
+	pauseLock sync.Mutex
+	// If not nil, records the described tasks, so unfinished tasks can be resumed.
+	journal *Journal
+	// The keys of the keyed tasks that didn't finish yet, mapped to the tasks waiting for them to finish.
+	keyedTasks map[string][]*task
+	// A lock on keyedTasks.
+	keyedTasksLock sync.Mutex
 }
 
 // Create a new capacity runner - a runner we can add tasks to without blocking as long as the capacity is not reached.
@@ -83,6 +186,35 @@ type runner struct {
 // acceptBeforeBlocking - number of tasks that can be added until a free processing goroutine is needed.
 // failFast - is set to true the will stop on first error.
 func NewRunner(maxParallel int, capacity uint, failFast bool) *runner {
+	return NewRunnerWithContext(context.Background(), maxParallel, capacity, failFast)
+}
+
+// Create a new capacity runner, which is cancelled when ctx is done.
+// Tasks added with AddContextTask receive a context derived from ctx, which is also cancelled when Cancel is called
+// or when a task fails and failFast is set.
+// maxParallel - number of go routines for task processing, maxParallel always will be a positive number.
+// capacity - number of tasks that can be added until a free processing goroutine is needed.
+// failFast - is set to true the will stop on first error.
+func NewRunnerWithContext(ctx context.Context, maxParallel int, capacity uint, failFast bool) *runner {
+	return newRunner(ctx, maxParallel, capacity, failFast, func(runnerCtx context.Context, capacity uint) taskQueue {
+		return newChannelQueue(runnerCtx, capacity)
+	})
+}
+
+// Create a new priority runner - a capacity runner with a task lane per priority.
+// Tasks added with AddTaskWithPriority are placed in the lane of their priority, and the threads always take the
+// next task from the highest non-empty lane. Tasks within the same lane are executed in the order they were added.
+// maxParallel - number of go routines for task processing, maxParallel always will be a positive number.
+// capacity - number of tasks (in all lanes) that can be added until a free processing goroutine is needed.
+// priorities - number of lanes. Priorities range from 0 (lowest) to priorities-1 (highest).
+// failFast - is set to true the will stop on first error.
+func NewPriorityRunner(maxParallel int, capacity uint, priorities int, failFast bool) *runner {
+	return newRunner(context.Background(), maxParallel, capacity, failFast, func(runnerCtx context.Context, capacity uint) taskQueue {
+		return newPriorityQueue(runnerCtx, capacity, priorities)
+	})
+}
+
+func newRunner(ctx context.Context, maxParallel int, capacity uint, failFast bool, newQueue func(context.Context, uint) taskQueue) *runner {
 	consumers := maxParallel
 	if consumers < 1 {
 		consumers = 1
@@ -92,12 +224,20 @@ func NewRunner(maxParallel int, capacity uint, failFast bool) *runner {
 	}
 	r := &runner{
 		finishedNotifier: make(chan bool, 1),
-		maxParallel:      consumers,
 		failFast:         failFast,
 		cancel:           atomic.Bool{},
-		tasks:            make(chan *task, capacity),
 	}
-	r.errors = make(map[int]error)
+	r.maxParallel.Store(int32(consumers))
+	r.ctx, r.cancelFunc = context.WithCancel(ctx)
+	r.tasks = newQueue(r.ctx, capacity)
+	r.failures = make(map[int]TaskFailure)
+	r.attempts = make(map[int]int)
+	r.runningTasks = make(map[int]time.Time)
+	r.keyedTasks = make(map[string][]*task)
+	// Cancelling the parent context cancels the runner.
+	r.stopParentCancel = context.AfterFunc(ctx, func() {
+		r.Cancel(false)
+	})
 	return r
 }
 
@@ -111,28 +251,123 @@ func NewBounedRunner(maxParallel int, failFast bool) *runner {
 
 // Add a task to the producer channel, in case of cancellation event (caused by @Cancel()) will return non nil error.
 func (r *runner) AddTask(t TaskFunc) (int, error) {
-	return r.addTask(t, nil)
+	return r.AddTaskWithOptions(withoutContext(t))
 }
 
 // t - the actual task which will be performed by the consumer.
 // onError - execute on the returned error while running t
 // Return the task number assigned to t. Useful to collect errors from the errors map (see @Errors())
 func (r *runner) AddTaskWithError(t TaskFunc, errorHandler OnErrorFunc) (int, error) {
-	return r.addTask(t, errorHandler)
+	return r.AddTaskWithOptions(withoutContext(t), WithErrorHandler(errorHandler))
+}
+
+// Add a context aware task to the producer channel, in case of cancellation event (caused by @Cancel()) will return non nil error.
+// The context passed to t is cancelled when the runner is cancelled.
+func (r *runner) AddContextTask(t ContextTaskFunc) (int, error) {
+	return r.AddTaskWithOptions(t)
+}
+
+// t - the actual task which will be performed by the consumer. The context passed to t is cancelled when the runner is cancelled.
+// onError - execute on the returned error while running t
+// Return the task number assigned to t. Useful to collect errors from the errors map (see @Errors())
+func (r *runner) AddContextTaskWithError(t ContextTaskFunc, errorHandler OnErrorFunc) (int, error) {
+	return r.AddTaskWithOptions(t, WithErrorHandler(errorHandler))
+}
+
+// Add a task to the lane of the given priority. Higher priority tasks are executed first.
+// Priorities out of the runner's range are assigned to the nearest lane.
+// In a runner that wasn't created with NewPriorityRunner, the priority is ignored.
+func (r *runner) AddTaskWithPriority(t TaskFunc, priority int) (int, error) {
+	return r.AddTaskWithOptions(withoutContext(t), WithPriority(priority))
+}
+
+// Add a task that is re-enqueued when it fails, according to the retry policy.
+// Only the error of the last attempt is saved in the errors map (see @Errors()) and passed to the error handlers.
+// The number of attempts is saved in the attempts map (see @Attempts()).
+func (r *runner) AddTaskWithRetry(t TaskFunc, policy RetryPolicy) (int, error) {
+	return r.AddTaskWithOptions(withoutContext(t), WithRetry(policy))
+}
+
+// Add a task with a cost, such as the size of the file the task transfers.
+// If SetMaxWeight was called, the task starts only when the total weight of the running tasks allows it.
+// Tasks heavier than the maximum weight are executed alone.
+func (r *runner) AddTaskWithWeight(t TaskFunc, weight int64) (int, error) {
+	return r.AddTaskWithOptions(withoutContext(t), WithWeight(weight))
+}
+
+// Add a context aware task, which is cancelled if an attempt of it doesn't finish within the timeout.
+// A timed out attempt fails with a TaskTimeoutError. If the task doesn't return when its context is cancelled, it
+// keeps running in the background while the thread moves on to the next task. Until it returns, it still holds its
+// weight and key (see @WithWeight() and @WithKey()).
+func (r *runner) AddContextTaskWithTimeout(t ContextTaskFunc, timeout time.Duration) (int, error) {
+	return r.AddTaskWithOptions(t, WithTimeout(timeout))
+}
+
+// Add a task with a label, such as the path of the file the task transfers. The label is reported if the task fails
+// (see @Err()).
+func (r *runner) AddTaskWithLabel(label string, t TaskFunc) (int, error) {
+	return r.AddTaskWithOptions(withoutContext(t), WithLabel(label))
+}
+
+// Add a context aware task with a serializable descriptor.
+// If the runner has a journal (see @SetJournal()), the task is recorded when it is added and when it finishes
+// successfully, so it can be resumed by a ResumeRunner if the process stops before it finishes.
+func (r *runner) AddDescribedTask(descriptor TaskDescriptor, t ContextTaskFunc) (int, error) {
+	return r.AddTaskWithOptions(t, WithDescriptor(descriptor))
+}
+
+// Add a context aware task configured by the options, such as a retried task with a key and a label.
+// In case of cancellation event (caused by @Cancel()) will return non nil error.
+func (r *runner) AddTaskWithOptions(t ContextTaskFunc, options ...TaskOption) (int, error) {
+	newTask := &task{run: t}
+	for _, option := range options {
+		option(newTask)
+	}
+	return r.addTask(newTask)
 }
 
-func (r *runner) addTask(t TaskFunc, errorHandler OnErrorFunc) (int, error) {
+func (r *runner) addTask(task *task) (int, error) {
 	nextCount := atomic.AddUint32(&r.taskId, 1)
-	task := &task{run: t, num: nextCount - 1, onError: errorHandler}
+	task.num = nextCount - 1
 
-	if r.cancel.Load() {
+	if r.cancel.Load() || r.ctx.Err() != nil {
 		return -1, errors.New("runner stopped")
 	}
+	if r.journal != nil && task.descriptor != nil && !task.resumed {
+		if err := r.journal.recordAdded(*task.descriptor); err != nil {
+			return -1, err
+		}
+	}
 	r.totalTasksInQueue.Add(1)
-	r.tasks <- task
+	if task.isDeferred() {
+		r.deferredTasksLock.Lock()
+		r.unfinishedDeferredTasks++
+		r.deferredTasksLock.Unlock()
+	}
+	if !r.acquireKey(task) {
+		// The task is held until the previous task with the same key finishes.
+		return int(task.num), nil
+	}
+	if !r.tasks.push(task) {
+		// The runner was cancelled while waiting for a free slot in the queue.
+		r.totalTasksInQueue.Add(^uint32(0))
+		r.finishTask(task)
+		return -1, errors.New("runner stopped")
+	}
 	return int(task.num), nil
 }
 
+// Returns true if the task may be pushed to the queue after Done is invoked.
+func (t *task) isDeferred() bool {
+	return t.retryPolicy != nil || t.key != ""
+}
+
+func withoutContext(t TaskFunc) ContextTaskFunc {
+	return func(_ context.Context, threadId int) error {
+		return t(threadId)
+	}
+}
+
 // Run r.maxParallel go routines in order to consume all the tasks
 // If a task returns an error and failFast is on all goroutines will stop and the runner will be notified.
 // Notice: Run() is a blocking operation.
@@ -147,16 +382,41 @@ func (r *runner) Run() {
 		}()
 	}
 
-	for i := 0; i < r.maxParallel; i++ {
+	if r.watchdogThreshold > 0 {
+		stopWatchdog := make(chan struct{})
+		defer close(stopWatchdog)
+		go r.runWatchdog(stopWatchdog)
+	}
+
+	for i := 0; i < r.MaxParallel(); i++ {
 		r.addThread()
 	}
 	r.threadsWaitGroup.Wait()
+	// Failed tasks waiting for a retry are dropped once the runner is cancelled.
+	r.retriesWaitGroup.Wait()
+	r.releaseContext()
+}
+
+// Release the runner's context, so the finished runner is no longer referenced by the parent context.
+func (r *runner) releaseContext() {
+	r.stopParentCancel()
+	r.cancelFunc()
 }
 
 // Done is used to notify that no more tasks will be produced.
+// Failed tasks waiting for a retry, and keyed tasks waiting for the previous tasks with their key, are still executed.
 func (r *runner) Done() {
+	r.deferredTasksLock.Lock()
+	defer r.deferredTasksLock.Unlock()
+	r.done = true
+	if r.unfinishedDeferredTasks == 0 {
+		r.closeTasks()
+	}
+}
+
+func (r *runner) closeTasks() {
 	r.doneOnce.Do(func() {
-		close(r.tasks)
+		r.tasks.close()
 	})
 }
 
@@ -172,19 +432,22 @@ func (r *runner) IsStarted() bool {
 }
 
 // Cancel stops the Runner from getting new tasks and empties the tasks queue.
-// No new tasks will be executed, and tasks that already started will continue running and won't be interrupted.
+// No new tasks will be executed. Tasks that already started will continue running, but the context passed to tasks
+// added with AddContextTask is cancelled, so they can stop early.
 // If this Runner is already cancelled, then this function will do nothing.
 // force - If true, pending tasks in the queue will not be handled.
 func (r *runner) Cancel(force bool) {
 	// No more adding tasks
 	r.cancel.Store(true)
+	// Notify the running tasks
+	r.releaseContext()
 	if force {
 		r.Done()
 	}
 	r.cancelOnce.Do(func() {
 		// Consume all tasks left
-		for len(r.tasks) > 0 {
-			<-r.tasks
+		for _, t := range r.takePendingTasks() {
+			r.dropTask(t)
 		}
 		if r.finishedNotificationEnabled {
 			r.notifyFinished()
@@ -194,7 +457,41 @@ func (r *runner) Cancel(force bool) {
 
 // Errors Returns a map of errors keyed by the task number
 func (r *runner) Errors() map[int]error {
-	return r.errors
+	r.errorsLock.Lock()
+	defer r.errorsLock.Unlock()
+	errs := make(map[int]error, len(r.failures))
+	for taskId, failure := range r.failures {
+		errs[taskId] = failure.Err
+	}
+	return errs
+}
+
+// Err returns a RunnerError with the failures of the tasks, or nil if no task failed.
+func (r *runner) Err() error {
+	r.errorsLock.Lock()
+	defer r.errorsLock.Unlock()
+	if len(r.failures) == 0 {
+		return nil
+	}
+	failures := make([]TaskFailure, 0, len(r.failures))
+	for _, failure := range r.failures {
+		failures = append(failures, failure)
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].TaskId < failures[j].TaskId
+	})
+	return &RunnerError{Failures: failures}
+}
+
+// Attempts returns a map of the number of attempts of the tasks added with a retry policy, keyed by the task number.
+func (r *runner) Attempts() map[int]int {
+	r.attemptsLock.Lock()
+	defer r.attemptsLock.Unlock()
+	attempts := make(map[int]int, len(r.attempts))
+	for taskId, attempt := range r.attempts {
+		attempts[taskId] = attempt
+	}
+	return attempts
 }
 
 // OpenThreads returns the number of open threads (including idle threads).
@@ -228,21 +525,67 @@ func (r *runner) ResetFinishNotificationIfActive() {
 	r.finishedNotifierChannelClosed = false
 }
 
+// SetRateLimit limits the number of tasks started per second, including retries.
+// Must be called before Run.
+// tasksPerSecond - the average number of tasks started per second. If not positive, the rate is not limited.
+// burst - the maximum number of tasks started at once, after a period with no tasks.
+func (r *runner) SetRateLimit(tasksPerSecond float64, burst int) {
+	if tasksPerSecond <= 0 {
+		r.rateLimiter = nil
+		return
+	}
+	r.rateLimiter = newRateLimiter(tasksPerSecond, burst)
+}
+
+// SetMaxWeight limits the total weight of the tasks running in parallel (see @AddTaskWithWeight()).
+// Tasks added without a weight are not limited.
+// Must be called before Run.
+// maxWeight - the maximum total weight. If not positive, the weight is not limited.
+func (r *runner) SetMaxWeight(maxWeight int64) {
+	if maxWeight <= 0 {
+		r.weights = nil
+		return
+	}
+	r.maxWeight = maxWeight
+	r.weights = semaphore.NewWeighted(maxWeight)
+}
+
+// SetDefaultTaskTimeout sets the maximum duration of each attempt of the tasks that were added without a timeout.
+// A timed out attempt fails with a TaskTimeoutError. Must be called before Run.
+// timeout - if not positive, such tasks have no timeout.
+func (r *runner) SetDefaultTaskTimeout(timeout time.Duration) {
+	r.defaultTaskTimeout = timeout
+}
+
+// SetJournal sets the journal recording the tasks added with AddDescribedTask. Must be called before adding tasks.
+func (r *runner) SetJournal(journal *Journal) {
+	r.journal = journal
+}
+
+// SetMaxParallel changes the maximum number of threads running in parallel. Safe to call while the runner is running.
 func (r *runner) SetMaxParallel(newVal int) {
 	if newVal < 1 {
 		newVal = 1
 	}
-	if newVal == r.maxParallel {
+	r.openThreadsLock.Lock()
+	defer r.openThreadsLock.Unlock()
+	maxParallel := r.MaxParallel()
+	if newVal == maxParallel {
 		return
 	}
-	if newVal > r.maxParallel {
-		for i := 0; i < newVal-r.maxParallel; i++ {
+	if newVal > maxParallel {
+		for i := 0; i < newVal-maxParallel; i++ {
 			r.addThread()
 		}
 	}
 	// In case the number of threads is reduced, we set the new value to maxParallel, and each thread that finishes his
 	// task checks if there are more open threads than maxParallel. If so, it kills itself.
-	r.maxParallel = newVal
+	r.maxParallel.Store(int32(newVal))
+}
+
+// MaxParallel returns the maximum number of threads running in parallel.
+func (r *runner) MaxParallel() int {
+	return int(r.maxParallel.Load())
 }
 
 func (r *runner) addThread() {
@@ -254,17 +597,56 @@ func (r *runner) addThread() {
 		r.openThreads.Add(1)
 		r.openThreadsLock.Unlock()
 
-		// Keep on taking tasks from the queue.
-		for t := range r.tasks {
+		// Keep on taking tasks from the queue, until the queue is closed or the runner is cancelled.
+		for {
+			if !r.waitWhilePaused() {
+				return
+			}
+			t, ok := r.tasks.pop()
+			if !ok {
+				return
+			}
+			if r.ctx.Err() != nil {
+				// The runner was cancelled while the task was taken from the queue.
+				r.dropTask(t)
+				return
+			}
+			if !r.holdWhilePaused(t) {
+				if r.ctx.Err() != nil {
+					return
+				}
+				// The task was drained while the runner was paused.
+				continue
+			}
+			if !r.acquire(t) {
+				// The runner was cancelled while waiting for the rate limit or the weight.
+				r.dropTask(t)
+				return
+			}
 			// Increase the total of active threads.
 			r.activeThreads.Add(1)
 			atomic.AddUint32(&r.started, 1)
 			// Run the task.
-			e := t.run(threadId)
+			r.recordAttempt(t)
+			start := time.Now()
+			r.taskStarted(t, threadId, start)
+			abandoned, e := r.runTask(t, threadId)
+			duration := time.Since(start)
+			r.afterAttempt(abandoned, func() {
+				r.release(t)
+				r.attemptReturned(t, start)
+			})
+			// A task waiting for a retry is still in progress.
+			retrying := e != nil && r.retry(t, e, abandoned)
+			r.taskFinished(t, e, duration, !retrying)
 			// Decrease the total of active threads.
 			r.activeThreads.Add(^uint32(0))
-			// Decrease the total of in progress tasks.
-			r.totalTasksInQueue.Add(^uint32(0))
+			if !retrying {
+				// Decrease the total of in progress tasks.
+				r.totalTasksInQueue.Add(^uint32(0))
+				r.afterAttempt(abandoned, func() { r.releaseKey(t) })
+				r.finishDeferredTask(t)
+			}
 			if r.finishedNotificationEnabled {
 				r.finishedNotifierLock.Lock()
 				// Notify that the runner has finished its job.
@@ -273,15 +655,22 @@ func (r *runner) addThread() {
 				}
 				r.finishedNotifierLock.Unlock()
 			}
+			r.notifyIdle()
 
-			if e != nil {
+			if !retrying && t.onFinish != nil {
+				t.onFinish(e)
+			}
+			if e == nil {
+				r.recordDone(t)
+			}
+			if e != nil && !retrying {
 				if t.onError != nil {
 					t.onError(e)
 				}
 
-				// Save the error in the errors map.
+				// Save the error in the failures map.
 				r.errorsLock.Lock()
-				r.errors[int(t.num)] = e
+				r.failures[int(t.num)] = TaskFailure{TaskId: int(t.num), Attempt: t.attempt, Duration: duration, Label: t.label, Err: e}
 				r.errorsLock.Unlock()
 
 				if r.failFast {
@@ -290,18 +679,192 @@ func (r *runner) addThread() {
 				}
 			}
 
-			r.openThreadsLock.Lock()
 			// If the total of open threads is larger than the maximum (maxParallel), then this thread should be closed.
-			if int(r.openThreads.Load()) > r.maxParallel {
-				r.openThreads.Add(^uint32(0))
+			// The lock is taken only in this case, to avoid contention between the threads after each task.
+			if int(r.openThreads.Load()) > r.MaxParallel() {
+				r.openThreadsLock.Lock()
+				if int(r.openThreads.Load()) > r.MaxParallel() {
+					r.openThreads.Add(^uint32(0))
+					r.openThreadsLock.Unlock()
+					break
+				}
 				r.openThreadsLock.Unlock()
-				break
 			}
-			r.openThreadsLock.Unlock()
 		}
 	}(int(nextThreadId))
 }
 
+// Run the task. If the task has a timeout and doesn't return within it, return a TaskTimeoutError without waiting for
+// the task to return. In this case, the returned channel is closed when the task returns.
+func (r *runner) runTask(t *task, threadId int) (<-chan struct{}, error) {
+	timeout := t.timeout
+	if timeout <= 0 {
+		timeout = r.defaultTaskTimeout
+	}
+	if timeout <= 0 {
+		return nil, r.runRecovered(r.ctx, t, threadId)
+	}
+
+	ctx, cancel := context.WithTimeout(r.ctx, timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	returned := make(chan struct{})
+	go func() {
+		defer close(returned)
+		done <- r.runRecovered(ctx, t, threadId)
+	}()
+	select {
+	case err := <-done:
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, TaskTimeoutError{TaskId: int(t.num), Timeout: timeout}
+		}
+		return nil, err
+	case <-ctx.Done():
+		if r.ctx.Err() != nil {
+			// The runner was cancelled, so wait for the task to return as tasks without a timeout do.
+			return nil, <-done
+		}
+		return returned, TaskTimeoutError{TaskId: int(t.num), Timeout: timeout}
+	}
+}
+
+// Call release once the attempt of the task returned. An attempt abandoned after its timeout keeps holding its weight
+// and key until it returns, so the tasks sharing them don't run concurrently with it.
+// abandoned - closed when the abandoned attempt returns, or nil if the attempt already returned.
+func (r *runner) afterAttempt(abandoned <-chan struct{}, release func()) {
+	if abandoned == nil {
+		release()
+		return
+	}
+	go func() {
+		<-abandoned
+		release()
+	}()
+}
+
+// Run the task, and convert a panic to a TaskPanicError.
+func (r *runner) runRecovered(ctx context.Context, t *task, threadId int) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = TaskPanicError{TaskId: int(t.num), Value: recovered, Stack: debug.Stack()}
+		}
+	}()
+	return t.run(ctx, threadId)
+}
+
+// Record the successful task in the journal, so it will not be resumed.
+func (r *runner) recordDone(t *task) {
+	if r.journal == nil || t.descriptor == nil {
+		return
+	}
+	if err := r.journal.recordDone(t.descriptor.Key); err != nil {
+		log.Warn(fmt.Sprintf("Task '%s' finished, but couldn't be recorded: %s", t.descriptor.Key, err.Error()))
+	}
+}
+
+// Wait until the rate limit and the total weight allow running the task.
+// Returns false if the runner was cancelled while waiting.
+func (r *runner) acquire(t *task) bool {
+	if r.rateLimiter != nil && r.rateLimiter.wait(r.ctx) != nil {
+		return false
+	}
+	if weight := r.weightOf(t); weight > 0 {
+		return r.weights.Acquire(r.ctx, weight) == nil
+	}
+	return true
+}
+
+func (r *runner) release(t *task) {
+	if weight := r.weightOf(t); weight > 0 {
+		r.weights.Release(weight)
+	}
+}
+
+// Returns the weight the task acquires while running, which is at most the maximum weight.
+func (r *runner) weightOf(t *task) int64 {
+	if r.weights == nil || t.weight <= 0 {
+		return 0
+	}
+	return min(t.weight, r.maxWeight)
+}
+
+func (r *runner) recordAttempt(t *task) {
+	t.attempt++
+	if t.retryPolicy == nil {
+		return
+	}
+	r.attemptsLock.Lock()
+	r.attempts[int(t.num)] = t.attempt
+	r.attemptsLock.Unlock()
+}
+
+// If the task should be retried according to its retry policy, re-enqueue it after the policy's backoff and return true.
+// abandoned - closed when the failed attempt returns, if it was abandoned after its timeout.
+func (r *runner) retry(t *task, err error, abandoned <-chan struct{}) bool {
+	if t.retryPolicy == nil || !t.retryPolicy.shouldRetry(t.attempt, err) || r.ctx.Err() != nil {
+		return false
+	}
+	// Re-enqueue in a different goroutine, since pushing to a full queue blocks until a thread is free.
+	t.retryInterval = t.retryPolicy.backoff(t.attempt, t.retryInterval, err)
+	r.retriesWaitGroup.Add(1)
+	go func() {
+		defer r.retriesWaitGroup.Done()
+		timer := time.NewTimer(t.retryInterval)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.ctx.Done():
+			// The runner was cancelled, so the task will not be executed.
+			r.dropTask(t)
+			return
+		}
+		if t.key != "" && abandoned != nil {
+			// The next attempt must not run concurrently with the abandoned attempt holding the key.
+			select {
+			case <-abandoned:
+			case <-r.ctx.Done():
+				r.dropTask(t)
+				return
+			}
+		}
+		if !r.tasks.push(t) {
+			// The runner was cancelled, so the task will not be executed.
+			r.dropTask(t)
+		}
+	}()
+	return true
+}
+
+// Called when a task is removed from the runner without being executed, since the runner was cancelled.
+func (r *runner) dropTask(t *task) {
+	r.cancelledTasks.Add(1)
+	// Decrease the total of in progress tasks.
+	r.totalTasksInQueue.Add(^uint32(0))
+	r.finishTask(t)
+	if t.onDrop != nil {
+		t.onDrop()
+	}
+}
+
+// Called when a task will not be executed anymore. Releases the task's key, so the next task with the same key is queued.
+func (r *runner) finishTask(t *task) {
+	r.releaseKey(t)
+	r.finishDeferredTask(t)
+}
+
+// Closes the tasks queue if Done was invoked and this was the last unfinished task with a retry policy or a key.
+func (r *runner) finishDeferredTask(t *task) {
+	if !t.isDeferred() {
+		return
+	}
+	r.deferredTasksLock.Lock()
+	defer r.deferredTasksLock.Unlock()
+	r.unfinishedDeferredTasks--
+	if r.done && r.unfinishedDeferredTasks == 0 {
+		r.closeTasks()
+	}
+}
+
 func (r *runner) notifyFinished() {
 	if !r.finishedNotifierChannelClosed {
 		r.finishedNotifier <- true
@@ -1,6 +1,7 @@
 package parallel
 
 import (
+	"context"
 	"crypto/rand"
 	"errors"
 	"fmt"
@@ -9,11 +10,17 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jfrog/gofrog/http/retryexecutor"
 	"github.com/stretchr/testify/assert"
 )
 
 var errTest = errors.New("some error")
 
+var (
+	_ Runner         = (*runner)(nil)
+	_ AdaptiveRunner = (*runner)(nil)
+)
+
 func TestIsStarted(t *testing.T) {
 	runner := NewBounedRunner(1, false)
 	_, err := runner.AddTask(func(i int) error {
@@ -231,3 +238,531 @@ func TestResetFinishNotificationIfActive(t *testing.T) {
 	assert.Zero(t, runnerTwo.ActiveThreads())
 	runnerTwo.Done()
 }
+
+func TestCancelParentContext(t *testing.T) {
+	// Create new runner with a cancellable parent context
+	ctx, cancel := context.WithCancel(context.Background())
+	runner := NewRunnerWithContext(ctx, 2, 10, false)
+
+	// Add tasks that wait for the context to be cancelled
+	started := make(chan bool, 2)
+	for i := 0; i < 2; i++ {
+		_, err := runner.AddContextTask(func(taskCtx context.Context, _ int) error {
+			started <- true
+			<-taskCtx.Done()
+			return taskCtx.Err()
+		})
+		assert.NoError(t, err)
+	}
+	go func() {
+		<-started
+		<-started
+		cancel()
+	}()
+
+	// Run returns once the tasks observe the cancellation, even though Done was never called
+	runner.Run()
+	assert.Len(t, runner.Errors(), 2)
+	for _, err := range runner.Errors() {
+		assert.ErrorIs(t, err, context.Canceled)
+	}
+
+	// Add another task and expect error
+	_, err := runner.AddTask(func(int) error { return nil })
+	assert.ErrorContains(t, err, "runner stopped")
+}
+
+func TestRunReleasesParentContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runner := NewRunnerWithContext(ctx, 2, 10, false)
+	_, err := runner.AddTask(func(int) error { return nil })
+	assert.NoError(t, err)
+	runner.Done()
+	runner.Run()
+
+	// The finished runner is no longer registered in the parent context, which is still alive
+	assert.False(t, runner.stopParentCancel())
+	assert.Error(t, runner.ctx.Err())
+	assert.NoError(t, ctx.Err())
+	assert.Empty(t, runner.Errors())
+}
+
+func TestCancelCancelsTaskContext(t *testing.T) {
+	// Create new runner
+	runner := NewBounedRunner(1, false)
+
+	// Add a task that waits for the context to be cancelled
+	started := make(chan bool)
+	var receivedError error
+	_, err := runner.AddContextTaskWithError(func(ctx context.Context, _ int) error {
+		close(started)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(waitForTasksTime):
+			return nil
+		}
+	}, func(err error) { receivedError = err })
+	assert.NoError(t, err)
+	go func() {
+		<-started
+		runner.Cancel(false)
+	}()
+
+	// Wait for the task to observe the cancellation
+	runner.Run()
+	assert.ErrorIs(t, receivedError, context.Canceled)
+}
+
+func TestFailFastCancelsTaskContext(t *testing.T) {
+	// Create new runner with fail-fast
+	runner := NewRunner(2, 2, true)
+
+	// Add a long task and a failing task
+	_, err := runner.AddContextTask(func(ctx context.Context, _ int) error {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(waitForTasksTime):
+			return errors.New("task was not cancelled")
+		}
+	})
+	assert.NoError(t, err)
+	_, err = runner.AddTask(func(int) error { return errTest })
+	assert.NoError(t, err)
+
+	// Run returns once the long task observes the cancellation
+	runner.Run()
+	assert.Equal(t, map[int]error{1: errTest}, runner.Errors())
+}
+
+func TestAddTaskWithPriority(t *testing.T) {
+	// Create new priority runner with a single thread, so the tasks are executed one by one
+	const tasksPerLane = 5
+	runner := NewPriorityRunner(1, 3*tasksPerLane, 3, false)
+
+	// Add the low priority tasks before the high priority tasks
+	var executed []int
+	for _, priority := range []int{0, 1, 2} {
+		for i := 0; i < tasksPerLane; i++ {
+			taskPriority := priority
+			_, err := runner.AddTaskWithPriority(func(int) error {
+				executed = append(executed, taskPriority)
+				return nil
+			}, taskPriority)
+			assert.NoError(t, err)
+		}
+	}
+	runner.Done()
+	runner.Run()
+
+	// Expect the higher lanes to be drained first
+	assert.Equal(t, []int{2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0}, executed)
+}
+
+func TestAddTaskWithPriorityOutOfRange(t *testing.T) {
+	// Create new priority runner with 2 lanes
+	runner := NewPriorityRunner(1, 3, 2, false)
+
+	// Priorities out of range are assigned to the nearest lane
+	var executed []int
+	for _, priority := range []int{-1, 5, 1} {
+		taskPriority := priority
+		_, err := runner.AddTaskWithPriority(func(int) error {
+			executed = append(executed, taskPriority)
+			return nil
+		}, taskPriority)
+		assert.NoError(t, err)
+	}
+	runner.Done()
+	runner.Run()
+	assert.Equal(t, []int{5, 1, -1}, executed)
+}
+
+func TestPriorityRunnerFailFast(t *testing.T) {
+	// Create new bounded priority runner with fail-fast
+	runner := NewPriorityRunner(2, 1, 2, true)
+
+	// Produce tasks until the runner stops
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer runner.Done()
+		for i := 0; ; i++ {
+			if _, err := runner.AddTaskWithPriority(func(int) error {
+				time.Sleep(time.Millisecond)
+				return errTest
+			}, i%2); err != nil {
+				assert.ErrorContains(t, err, "runner stopped")
+				return
+			}
+		}
+	}()
+	runner.Run()
+	wg.Wait()
+	assert.NotEmpty(t, runner.Errors())
+}
+
+func TestAddTaskWithRetry(t *testing.T) {
+	// Create new runner, with a single thread
+	runner := NewRunner(1, 2, false)
+
+	// Add a task that succeeds on the third attempt and a task that always fails
+	var succeedingAttempts, failingAttempts int
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: retryexecutor.ConstantBackoff(time.Millisecond)}
+	succeedingTaskId, err := runner.AddTaskWithRetry(func(int) error {
+		succeedingAttempts++
+		if succeedingAttempts < 3 {
+			return errTest
+		}
+		return nil
+	}, policy)
+	assert.NoError(t, err)
+	failingTaskId, err := runner.AddTaskWithRetry(func(int) error {
+		failingAttempts++
+		return fmt.Errorf("attempt %d", failingAttempts)
+	}, policy)
+	assert.NoError(t, err)
+
+	// Done is called before the retries are re-enqueued
+	runner.Done()
+	runner.Run()
+
+	// Only the error of the last attempt is saved
+	assert.Equal(t, 3, succeedingAttempts)
+	assert.Equal(t, 3, failingAttempts)
+	assert.Equal(t, map[int]error{failingTaskId: errors.New("attempt 3")}, runner.Errors())
+	assert.Equal(t, map[int]int{succeedingTaskId: 3, failingTaskId: 3}, runner.Attempts())
+}
+
+func TestAddTaskWithRetryNotRetryable(t *testing.T) {
+	// Create new runner
+	runner := NewRunner(1, 1, false)
+
+	// Add a task that returns a non retryable error
+	var attempts int
+	policy := RetryPolicy{MaxAttempts: 5, IsRetryable: func(err error) bool { return !errors.Is(err, errTest) }}
+	_, err := runner.AddTaskWithRetry(func(int) error {
+		attempts++
+		return errTest
+	}, policy)
+	assert.NoError(t, err)
+	runner.Done()
+	runner.Run()
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, map[int]int{0: 1}, runner.Attempts())
+
+}
+
+func TestAddTaskWithRetryFailFast(t *testing.T) {
+	// Create new runner with fail-fast
+	runner := NewRunner(2, 10, true)
+
+	// The runner is cancelled only after the last attempt fails
+	var attempts int
+	_, err := runner.AddTaskWithRetry(func(int) error {
+		attempts++
+		return errTest
+	}, RetryPolicy{MaxAttempts: 3})
+	assert.NoError(t, err)
+	runner.Run()
+	assert.Equal(t, 3, attempts)
+	assert.Len(t, runner.Errors(), 1)
+
+	// Add another task and expect error
+	_, err = runner.AddTask(func(int) error { return nil })
+	assert.ErrorContains(t, err, "runner stopped")
+}
+
+func TestAddTaskWithRetryCancel(t *testing.T) {
+	// Create new runner, and add a task that always fails with a long backoff
+	runner := NewRunner(1, 1, false)
+	attemptDone := make(chan struct{}, 1)
+	runner.OnTaskDone(func(int, error, time.Duration) {
+		attemptDone <- struct{}{}
+	})
+	_, err := runner.AddTaskWithRetry(func(int) error {
+		return errTest
+	}, RetryPolicy{MaxAttempts: 5, Backoff: retryexecutor.ConstantBackoff(time.Minute)})
+	assert.NoError(t, err)
+	runner.Done()
+
+	// Cancel the runner while the task waits for its retry
+	start := time.Now()
+	go func() {
+		<-attemptDone
+		runner.Cancel(false)
+	}()
+	runner.Run()
+
+	// Expect the task to be dropped without waiting for the backoff
+	assert.Less(t, time.Since(start), time.Minute)
+	stats := runner.Stats()
+	assert.Equal(t, uint32(1), stats.Cancelled)
+	assert.Equal(t, uint32(0), stats.Queued)
+}
+
+func TestAddTaskWithRetryBackoff(t *testing.T) {
+	// Create new runner, and add a task with a backoff strategy recording its arguments
+	runner := NewRunner(1, 1, false)
+	var backoffs [][2]time.Duration
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: func(attempt int, previous time.Duration) time.Duration {
+		backoffs = append(backoffs, [2]time.Duration{time.Duration(attempt), previous})
+		return time.Duration(attempt+1) * time.Millisecond
+	}}
+	_, err := runner.AddTaskWithRetry(func(int) error { return errTest }, policy)
+	assert.NoError(t, err)
+	runner.Done()
+	runner.Run()
+
+	// The attempts passed to the strategy start from 0, as in retryexecutor
+	assert.Equal(t, [][2]time.Duration{{0, 0}, {1, time.Millisecond}}, backoffs)
+}
+
+func TestAddTaskWithRetryAfter(t *testing.T) {
+	// Create new runner, and add a task suggesting a retry delay shorter than its backoff
+	runner := NewRunner(1, 1, false)
+	var attempts int
+	policy := RetryPolicy{MaxAttempts: 2, Backoff: retryexecutor.ConstantBackoff(time.Hour)}
+	_, err := runner.AddTaskWithRetry(func(int) error {
+		attempts++
+		if attempts == 1 {
+			return retryexecutor.RetryAfterError{Delay: 20 * time.Millisecond, Err: errTest}
+		}
+		return nil
+	}, policy)
+	assert.NoError(t, err)
+
+	// Expect the task to be retried after the suggested delay, instead of the backoff
+	start := time.Now()
+	runner.Done()
+	runner.Run()
+	assert.Equal(t, 2, attempts)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	assert.Less(t, time.Since(start), time.Minute)
+	assert.Empty(t, runner.Errors())
+}
+
+func TestSetRateLimit(t *testing.T) {
+	// Create new runner limited to 20 tasks per second
+	const count = 6
+	runner := NewRunner(count, count, false)
+	runner.SetRateLimit(20, 1)
+	for i := 0; i < count; i++ {
+		_, err := runner.AddTask(func(int) error { return nil })
+		assert.NoError(t, err)
+	}
+
+	// The first task starts immediately, and each of the other tasks waits for 50 milliseconds
+	start := time.Now()
+	runner.Done()
+	runner.Run()
+	assert.GreaterOrEqual(t, time.Since(start), 250*time.Millisecond)
+}
+
+func TestSetRateLimitCancel(t *testing.T) {
+	// Create new runner limited to a task per minute
+	runner := NewRunner(2, 2, false)
+	runner.SetRateLimit(1.0/60, 1)
+	executed := 0
+	for i := 0; i < 2; i++ {
+		_, err := runner.AddTask(func(int) error {
+			executed++
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+
+	// Cancelling the runner stops waiting for the rate limit
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		runner.Cancel(true)
+	}()
+	runner.Run()
+	assert.Equal(t, 1, executed)
+}
+
+func TestAddTaskWithWeight(t *testing.T) {
+	// Create new runner limited to a total weight of 10
+	const count = 10
+	runner := NewRunner(count, count, false)
+	runner.SetMaxWeight(10)
+
+	var runningWeight, maxRunningWeight int64
+	var weightLock sync.Mutex
+	for i := 0; i < count; i++ {
+		// The last task is heavier than the maximum weight, so it runs alone
+		weight := int64(i%3 + 3)
+		if i == count-1 {
+			weight = 100
+		}
+		_, err := runner.AddTaskWithWeight(func(int) error {
+			weightLock.Lock()
+			runningWeight += min(weight, 10)
+			maxRunningWeight = max(maxRunningWeight, runningWeight)
+			weightLock.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			weightLock.Lock()
+			runningWeight -= min(weight, 10)
+			weightLock.Unlock()
+			return nil
+		}, weight)
+		assert.NoError(t, err)
+	}
+	runner.Done()
+	runner.Run()
+	assert.LessOrEqual(t, maxRunningWeight, int64(10))
+	assert.Equal(t, uint32(count), runner.started)
+}
+
+func TestTaskPanic(t *testing.T) {
+	// Create new runner
+	runner := NewRunner(2, 3, false)
+
+	// Add a panicking task between two successful tasks
+	var receivedError error
+	executed := 0
+	var executedLock sync.Mutex
+	successfulTask := func(int) error {
+		executedLock.Lock()
+		defer executedLock.Unlock()
+		executed++
+		return nil
+	}
+	_, err := runner.AddTask(successfulTask)
+	assert.NoError(t, err)
+	panickingTaskId, err := runner.AddTaskWithError(func(int) error {
+		panic("unexpected")
+	}, func(err error) { receivedError = err })
+	assert.NoError(t, err)
+	_, err = runner.AddTask(successfulTask)
+	assert.NoError(t, err)
+	runner.Done()
+	runner.Run()
+
+	// Expect the panic to be recorded as an error
+	assert.Equal(t, 2, executed)
+	var panicErr TaskPanicError
+	assert.ErrorAs(t, runner.Errors()[panickingTaskId], &panicErr)
+	assert.Equal(t, receivedError, runner.Errors()[panickingTaskId])
+	assert.Equal(t, panickingTaskId, panicErr.TaskId)
+	assert.Equal(t, "unexpected", panicErr.Value)
+	assert.Contains(t, string(panicErr.Stack), "TestTaskPanic")
+	assert.Equal(t, uint32(1), runner.Stats().Failed)
+}
+
+func TestTaskPanicFailFast(t *testing.T) {
+	// Create new runner with fail-fast
+	runner := NewBounedRunner(1, true)
+
+	// Add a task panicking with an error
+	_, err := runner.AddTask(func(int) error {
+		panic(errTest)
+	})
+	assert.NoError(t, err)
+	runner.Run()
+	assert.ErrorIs(t, runner.Errors()[0], errTest)
+
+	// Add another task and expect error
+	_, err = runner.AddTask(func(int) error { return nil })
+	assert.ErrorContains(t, err, "runner stopped")
+}
+
+func TestAddContextTaskWithTimeout(t *testing.T) {
+	// Create new runner
+	runner := NewRunner(1, 2, false)
+
+	// Add a task observing its context, and a fast task
+	timedOutTaskId, err := runner.AddContextTaskWithTimeout(func(ctx context.Context, _ int) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, 10*time.Millisecond)
+	assert.NoError(t, err)
+	fastTaskId, err := runner.AddContextTaskWithTimeout(func(context.Context, int) error {
+		return nil
+	}, time.Minute)
+	assert.NoError(t, err)
+	runner.Done()
+	runner.Run()
+
+	assert.Equal(t, map[int]error{timedOutTaskId: TaskTimeoutError{TaskId: timedOutTaskId, Timeout: 10 * time.Millisecond}}, runner.Errors())
+	assert.ErrorIs(t, runner.Errors()[timedOutTaskId], context.DeadlineExceeded)
+	assert.NotContains(t, runner.Errors(), fastTaskId)
+}
+
+func TestSetDefaultTaskTimeout(t *testing.T) {
+	// Create new runner with a default timeout
+	runner := NewRunner(1, 2, false)
+	runner.SetDefaultTaskTimeout(10 * time.Millisecond)
+
+	// Add a hung task ignoring its context
+	hung := make(chan bool)
+	defer close(hung)
+	_, err := runner.AddTask(func(int) error {
+		<-hung
+		return nil
+	})
+	assert.NoError(t, err)
+	executed := false
+	_, err = runner.AddTask(func(int) error {
+		executed = true
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// Run returns without waiting for the hung task
+	runner.Done()
+	runner.Run()
+	assert.ErrorAs(t, runner.Errors()[0], &TaskTimeoutError{})
+	assert.True(t, executed)
+}
+
+func TestWatchdog(t *testing.T) {
+	// Create new runner with a watchdog
+	runner := NewRunner(2, 2, false)
+	runner.SetWatchdog(10 * time.Millisecond)
+
+	// Add a slow task and a fast task
+	checked := make(chan bool)
+	_, err := runner.AddTask(func(int) error {
+		<-checked
+		return nil
+	})
+	assert.NoError(t, err)
+	_, err = runner.AddTask(func(int) error { return nil })
+	assert.NoError(t, err)
+	go func() {
+		defer close(checked)
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, []int{0}, runner.stuckTasks(10*time.Millisecond))
+		assert.Empty(t, runner.stuckTasks(time.Minute))
+	}()
+	runner.Done()
+	runner.Run()
+	assert.Empty(t, runner.stuckTasks(0))
+}
+
+func TestWatchdogTimedOutTask(t *testing.T) {
+	// Create new runner with a watchdog, and add a task that ignores its context after its timeout
+	runner := NewRunner(1, 1, false)
+	runner.SetWatchdog(time.Minute)
+	runner.SetDefaultTaskTimeout(10 * time.Millisecond)
+	unblock := make(chan struct{})
+	_, err := runner.AddTask(func(int) error {
+		<-unblock
+		return nil
+	})
+	assert.NoError(t, err)
+	runner.Done()
+	runner.Run()
+	assert.ErrorAs(t, runner.Errors()[0], &TaskTimeoutError{})
+
+	// The abandoned task is still running, so it is watched until it returns
+	assert.Equal(t, []int{0}, runner.stuckTasks(0))
+	close(unblock)
+	assert.Eventually(t, func() bool {
+		return len(runner.stuckTasks(0)) == 0
+	}, time.Second, time.Millisecond)
+}
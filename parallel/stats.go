@@ -0,0 +1,213 @@
+package parallel
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// The number of the last attempt durations kept by the runner (see @RunnerStats.RecentDurations).
+const recentDurationsSize = 100
+
+// RunnerStats is a snapshot of the runner's progress.
+type RunnerStats struct {
+	// The number of tasks waiting in the queue, or waiting for a retry.
+	Queued uint32
+	// The number of tasks currently running.
+	Running uint32
+	// The number of tasks that finished successfully.
+	Succeeded uint32
+	// The number of tasks that failed. Tasks waiting for a retry are not counted.
+	Failed uint32
+	// The number of tasks that were not executed, since the runner was cancelled.
+	Cancelled uint32
+	// Aggregated durations of all the attempts of the tasks.
+	Durations DurationStats
+	// The durations of the last 100 attempts of the tasks, in the order they finished. Earlier durations are only
+	// included in Durations, so callers needing the duration of every task should collect them with OnTaskDone.
+	RecentDurations []TaskDuration
+	// The time passed from the start of the first task to the end of the last finished task.
+	Elapsed time.Duration
+	// The number of finished tasks per second, during the elapsed time.
+	Throughput float64
+}
+
+// DurationStats aggregates the durations of the attempts of the tasks.
+type DurationStats struct {
+	// The number of attempts.
+	Count int64
+	// The total duration of the attempts.
+	Total time.Duration
+	// The duration of the shortest attempt.
+	Min time.Duration
+	// The duration of the longest attempt.
+	Max time.Duration
+}
+
+// TaskDuration is the duration of an attempt of a task.
+type TaskDuration struct {
+	// The task number, as returned when the task was added.
+	TaskId   int
+	Duration time.Duration
+}
+
+// Mean returns the average duration of the attempts, or zero if there are none.
+func (stats DurationStats) Mean() time.Duration {
+	if stats.Count == 0 {
+		return 0
+	}
+	return stats.Total / time.Duration(stats.Count)
+}
+
+// TaskStartHook is invoked when a task starts running, from the thread running it.
+type TaskStartHook func(taskId, threadId int)
+
+// TaskDoneHook is invoked after each attempt of a task, from the thread that ran it.
+// err - the error returned from the task, or nil if it succeeded.
+type TaskDoneHook func(taskId int, err error, duration time.Duration)
+
+// IdleHook is invoked when a task finishes and there are no more running or queued tasks.
+type IdleHook func()
+
+// Stats returns a snapshot of the runner's progress.
+// The snapshot keeps the memory of the runner bounded: the durations of all the attempts are aggregated, and only the
+// last 100 of them are listed in RecentDurations. Use OnTaskDone to collect the duration of every task.
+func (r *runner) Stats() RunnerStats {
+	stats := RunnerStats{
+		Running:   r.activeThreads.Load(),
+		Succeeded: r.succeededTasks.Load(),
+		Failed:    r.failedTasks.Load(),
+		Cancelled: r.cancelledTasks.Load(),
+	}
+	if inProgress := r.totalTasksInQueue.Load(); inProgress > stats.Running {
+		stats.Queued = inProgress - stats.Running
+	}
+	stats.Durations, stats.RecentDurations = r.durations.snapshot()
+	firstTaskStart, lastTaskEnd := r.firstTaskStart.Load(), r.lastTaskEnd.Load()
+	if firstTaskStart != 0 && lastTaskEnd > firstTaskStart {
+		stats.Elapsed = time.Duration(lastTaskEnd - firstTaskStart)
+		stats.Throughput = float64(stats.Succeeded+stats.Failed) / stats.Elapsed.Seconds()
+	}
+	return stats
+}
+
+// OnTaskStart registers a hook invoked when a task starts running. Must be called before Run.
+func (r *runner) OnTaskStart(hook TaskStartHook) {
+	r.taskStartHooks = append(r.taskStartHooks, hook)
+}
+
+// OnTaskDone registers a hook invoked after each attempt of a task, with its duration. Must be called before Run.
+// Since Stats lists only the last attempts, callers needing the durations of all the tasks should record them here.
+func (r *runner) OnTaskDone(hook TaskDoneHook) {
+	r.taskDoneHooks = append(r.taskDoneHooks, hook)
+}
+
+// OnIdle registers a hook invoked when a task finishes and there are no more running or queued tasks.
+// The hook may be invoked multiple times, whenever the runner becomes idle. Must be called before Run.
+func (r *runner) OnIdle(hook IdleHook) {
+	r.idleHooks = append(r.idleHooks, hook)
+}
+
+func (r *runner) taskStarted(t *task, threadId int, start time.Time) {
+	if r.firstTaskStart.Load() == 0 {
+		r.firstTaskStart.CompareAndSwap(0, start.UnixNano())
+	}
+	if r.watchdogThreshold > 0 {
+		r.runningTasksLock.Lock()
+		r.runningTasks[int(t.num)] = start
+		r.runningTasksLock.Unlock()
+	}
+	for _, hook := range r.taskStartHooks {
+		hook(int(t.num), threadId)
+	}
+}
+
+// Record the attempt of the task.
+// finished - false if the task failed and is waiting for a retry.
+func (r *runner) taskFinished(t *task, err error, duration time.Duration, finished bool) {
+	r.durations.record(int(t.num), duration)
+	storeMax(&r.lastTaskEnd, time.Now().UnixNano())
+	if finished {
+		if err == nil {
+			r.succeededTasks.Add(1)
+		} else {
+			r.failedTasks.Add(1)
+		}
+	}
+	for _, hook := range r.taskDoneHooks {
+		hook(int(t.num), err, duration)
+	}
+}
+
+// Stop watching the attempt of the task that started at the given time, once it returned. An attempt abandoned after
+// its timeout is watched until it returns, since it is still running.
+func (r *runner) attemptReturned(t *task, start time.Time) {
+	if r.watchdogThreshold <= 0 {
+		return
+	}
+	r.runningTasksLock.Lock()
+	defer r.runningTasksLock.Unlock()
+	// A later attempt of the task may already be running.
+	if r.runningTasks[int(t.num)].Equal(start) {
+		delete(r.runningTasks, int(t.num))
+	}
+}
+
+func (r *runner) notifyIdle() {
+	if len(r.idleHooks) == 0 || r.activeThreads.Load() != 0 || r.totalTasksInQueue.Load() != 0 {
+		return
+	}
+	for _, hook := range r.idleHooks {
+		hook()
+	}
+}
+
+// Records the durations of the attempts without a lock, since all the threads update it after each attempt.
+type durationRecorder struct {
+	count atomic.Int64
+	total atomic.Int64
+	// The shortest duration plus one, so zero means no duration was recorded.
+	min atomic.Int64
+	max atomic.Int64
+	// The last durations, indexed by their number modulo recentDurationsSize.
+	recent [recentDurationsSize]atomic.Pointer[TaskDuration]
+}
+
+func (d *durationRecorder) record(taskId int, duration time.Duration) {
+	number := d.count.Add(1) - 1
+	d.total.Add(int64(duration))
+	d.recent[number%recentDurationsSize].Store(&TaskDuration{TaskId: taskId, Duration: duration})
+	storeMax(&d.max, int64(duration))
+	for {
+		current := d.min.Load()
+		if current != 0 && current <= int64(duration)+1 || d.min.CompareAndSwap(current, int64(duration)+1) {
+			return
+		}
+	}
+}
+
+// Returns the aggregated durations and the last durations. While tasks are running, the snapshot is approximate.
+func (d *durationRecorder) snapshot() (DurationStats, []TaskDuration) {
+	count := d.count.Load()
+	stats := DurationStats{Count: count, Total: time.Duration(d.total.Load()), Max: time.Duration(d.max.Load())}
+	if minDuration := d.min.Load(); minDuration > 0 {
+		stats.Min = time.Duration(minDuration - 1)
+	}
+	recent := make([]TaskDuration, 0, min(count, recentDurationsSize))
+	for number := max(count-recentDurationsSize, 0); number < count; number++ {
+		// The duration may not be stored yet, if its attempt is being recorded.
+		if taskDuration := d.recent[number%recentDurationsSize].Load(); taskDuration != nil {
+			recent = append(recent, *taskDuration)
+		}
+	}
+	return stats, recent
+}
+
+// Store the value if it is larger than the current value.
+func storeMax(current *atomic.Int64, value int64) {
+	for {
+		old := current.Load()
+		if old >= value || current.CompareAndSwap(old, value) {
+			return
+		}
+	}
+}
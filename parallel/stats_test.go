@@ -0,0 +1,144 @@
+package parallel
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	// Create new runner
+	const count = 10
+	runner := NewRunner(2, count, false)
+	for i := 0; i < count; i++ {
+		fail := i%4 == 0
+		_, err := runner.AddTask(func(int) error {
+			time.Sleep(5 * time.Millisecond)
+			if fail {
+				return errTest
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+	stats := runner.Stats()
+	assert.Equal(t, uint32(count), stats.Queued)
+	assert.Zero(t, stats.Running)
+	assert.Zero(t, stats.Throughput)
+
+	runner.Done()
+	runner.Run()
+
+	stats = runner.Stats()
+	assert.Zero(t, stats.Queued)
+	assert.Zero(t, stats.Running)
+	assert.Equal(t, uint32(7), stats.Succeeded)
+	assert.Equal(t, uint32(3), stats.Failed)
+	assert.Zero(t, stats.Cancelled)
+	assert.Equal(t, int64(count), stats.Durations.Count)
+	assert.GreaterOrEqual(t, stats.Durations.Min, 5*time.Millisecond)
+	assert.GreaterOrEqual(t, stats.Durations.Max, stats.Durations.Min)
+	assert.GreaterOrEqual(t, stats.Durations.Mean(), stats.Durations.Min)
+	assert.LessOrEqual(t, stats.Durations.Mean(), stats.Durations.Max)
+	assert.Len(t, stats.RecentDurations, count)
+	taskIds := make([]int, 0, count)
+	for _, taskDuration := range stats.RecentDurations {
+		taskIds = append(taskIds, taskDuration.TaskId)
+		assert.GreaterOrEqual(t, taskDuration.Duration, 5*time.Millisecond)
+	}
+	assert.ElementsMatch(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, taskIds)
+	assert.Greater(t, stats.Elapsed, time.Duration(0))
+	assert.Greater(t, stats.Throughput, float64(0))
+}
+
+func TestDurationRecorder(t *testing.T) {
+	var recorder durationRecorder
+	stats, recent := recorder.snapshot()
+	assert.Zero(t, stats)
+	assert.Zero(t, stats.Mean())
+	assert.Empty(t, recent)
+
+	// Only the last durations are kept, while the aggregated durations include all of them
+	for i := 0; i < recentDurationsSize+10; i++ {
+		recorder.record(i, time.Duration(i))
+	}
+	stats, recent = recorder.snapshot()
+	assert.Equal(t, DurationStats{Count: recentDurationsSize + 10, Total: 5995, Min: 0, Max: recentDurationsSize + 9}, stats)
+	assert.Equal(t, time.Duration(54), stats.Mean())
+	assert.Len(t, recent, recentDurationsSize)
+	assert.Equal(t, TaskDuration{TaskId: 10, Duration: 10}, recent[0])
+	assert.Equal(t, TaskDuration{TaskId: recentDurationsSize + 9, Duration: recentDurationsSize + 9}, recent[recentDurationsSize-1])
+}
+
+func TestStatsCancelled(t *testing.T) {
+	// Create new runner with a single thread
+	const count = 5
+	runner := NewRunner(1, count, false)
+	started := make(chan bool)
+	_, err := runner.AddTask(func(int) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+	assert.NoError(t, err)
+	for i := 1; i < count; i++ {
+		_, err = runner.AddTask(func(int) error { return nil })
+		assert.NoError(t, err)
+	}
+	go func() {
+		<-started
+		runner.Cancel(true)
+	}()
+	runner.Run()
+
+	stats := runner.Stats()
+	assert.Equal(t, uint32(1), stats.Succeeded)
+	assert.Equal(t, uint32(count-1), stats.Cancelled)
+	assert.Zero(t, stats.Queued)
+}
+
+func TestHooks(t *testing.T) {
+	// Create new runner with hooks
+	const count = 6
+	runner := NewRunner(3, count, false)
+	var lock sync.Mutex
+	started := make(map[int]bool)
+	done := make(map[int]error)
+	attempts := 0
+	idle := 0
+	runner.OnTaskStart(func(taskId, threadId int) {
+		lock.Lock()
+		defer lock.Unlock()
+		assert.Less(t, threadId, 3)
+		started[taskId] = true
+	})
+	runner.OnTaskDone(func(taskId int, err error, duration time.Duration) {
+		lock.Lock()
+		defer lock.Unlock()
+		attempts++
+		done[taskId] = err
+	})
+	runner.OnIdle(func() {
+		lock.Lock()
+		defer lock.Unlock()
+		idle++
+	})
+
+	// The retried task invokes the done hook on each attempt
+	retriedTaskId, err := runner.AddTaskWithRetry(func(int) error { return errTest }, RetryPolicy{MaxAttempts: 2})
+	assert.NoError(t, err)
+	for i := 1; i < count; i++ {
+		_, err = runner.AddTask(func(int) error { return nil })
+		assert.NoError(t, err)
+	}
+	runner.Done()
+	runner.Run()
+
+	assert.Len(t, started, count)
+	assert.Len(t, done, count)
+	assert.Equal(t, count+1, attempts)
+	assert.Equal(t, errTest, done[retriedTaskId])
+	assert.GreaterOrEqual(t, idle, 1)
+}
@@ -0,0 +1,45 @@
+package parallel
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jfrog/gofrog/log"
+)
+
+// SetWatchdog enables logging a warning with the ids of the tasks that have been running longer than the threshold.
+// The running tasks are checked once every threshold, while the runner is running. Must be called before Run.
+// threshold - if not positive, the watchdog is disabled.
+func (r *runner) SetWatchdog(threshold time.Duration) {
+	r.watchdogThreshold = threshold
+}
+
+func (r *runner) runWatchdog(stop <-chan struct{}) {
+	ticker := time.NewTicker(r.watchdogThreshold)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if stuckTasks := r.stuckTasks(r.watchdogThreshold); len(stuckTasks) > 0 {
+				log.Warn(fmt.Sprintf("%d tasks have been running for more than %s: %v", len(stuckTasks), r.watchdogThreshold, stuckTasks))
+			}
+		}
+	}
+}
+
+// Returns the sorted ids of the tasks that have been running longer than the threshold.
+func (r *runner) stuckTasks(threshold time.Duration) []int {
+	r.runningTasksLock.Lock()
+	defer r.runningTasksLock.Unlock()
+	var stuckTasks []int
+	for taskId, start := range r.runningTasks {
+		if time.Since(start) > threshold {
+			stuckTasks = append(stuckTasks, taskId)
+		}
+	}
+	sort.Ints(stuckTasks)
+	return stuckTasks
+}